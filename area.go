@@ -15,6 +15,7 @@ type Area struct {
 	viewEngine *ViewEngine
 	hijackColl hijackContainer
 	engineLock sync.RWMutex
+	cors       *corsPolicy
 }
 
 // Key get the area key/pathPrefix
@@ -50,12 +51,29 @@ func (a *Area) ExtendView(name string, f interface{}) {
 	a.viewEngine.ExtendView(name, f)
 }
 
+// SetLayout set the default layout of the area views. The '_viewstart' files in the area views override the default layout
+func (a *Area) SetLayout(layout string) {
+	a.server.assertUnlocked()
+	a.initViewEngine()
+	a.viewEngine.SetLayout(layout)
+}
+
+// SetViewLocations set the directories relative to the area view directory where the views are searched in order.
+// The views that are not found in the area are searched in the view locations of the server
+func (a *Area) SetViewLocations(locations ...string) {
+	a.server.assertUnlocked()
+	a.initViewEngine()
+	a.viewEngine.SetLocations(locations...)
+}
+
 func (a *Area) initViewEngine() {
 	if a.viewEngine == nil {
 		a.engineLock.Lock()
 		defer a.engineLock.Unlock()
 		if a.viewEngine == nil {
-			a.viewEngine = NewViewEngine(a.server.MapRootPath(a.Key() + "/views"))
+			a.server.initViewEngine()
+			a.viewEngine = a.server.newViewEngine(a.Key() + "/views")
+			a.viewEngine.fallback = a.server.viewEngine
 		}
 	}
 }
@@ -0,0 +1,318 @@
+package mego
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/simbory/mego/assert"
+	"github.com/simbory/mego/fswatcher"
+)
+
+// AssetOption the options of the asset pipeline
+type AssetOption struct {
+	// MaxAge the max age of the fingerprinted assets in the 'Cache-Control' header, the default value is one year
+	MaxAge time.Duration
+	// Minify minify the CSS and JS files. The minification is conservative: the comments and the white spaces around
+	// the braces, the semicolons and the commas of the CSS are removed, and the comments and the blank lines of the
+	// JS are removed. The names and the other white spaces are not changed
+	Minify bool
+}
+
+// asset the fingerprinted asset
+type asset struct {
+	// name the asset path relative to the content root
+	name string
+	// url the fingerprinted url
+	url     string
+	hash    string
+	modTime time.Time
+	// content the content of the bundle, it's nil for the files
+	content []byte
+}
+
+// assetPipeline fingerprint the files in the content root and serve them with the immutable cache headers
+type assetPipeline struct {
+	fsys    fs.FS
+	opt     AssetOption
+	lock    sync.RWMutex
+	assets  map[string]*asset
+	bundles map[string][]string
+	watcher *fswatcher.FileWatcher
+}
+
+// assetHashLen the length of the content hash in the fingerprinted file name
+const assetHashLen = 10
+
+var assetHashReg = regexp.MustCompile("^[0-9a-f]{10}$")
+
+// fingerprint get the fingerprinted url of the asset name: 'css/site.css' -> '/css/site.0123456789.css', and
+// 'LICENSE' -> '/LICENSE.0123456789'
+func fingerprint(name, hash string) string {
+	ext := path.Ext(name)
+	return strAdd("/", strings.TrimSuffix(name, ext), ".", hash, ext)
+}
+
+// assetRef the asset name and the hash of the fingerprinted url
+type assetRef struct {
+	name string
+	hash string
+}
+
+// unfingerprint get the possible asset names and hashes of the fingerprinted url. The url '/a.0123456789.css' is the
+// fingerprinted url of 'a.css', and the url '/a.0123456789' is the fingerprinted url of the name 'a' without extension
+func unfingerprint(urlPath string) []assetRef {
+	var refs []assetRef
+	ext := path.Ext(urlPath)
+	base := strings.TrimSuffix(urlPath, ext)
+	if i := strings.LastIndexByte(base, '.'); i >= 0 && i > strings.LastIndexByte(base, '/') && assetHashReg.MatchString(base[i+1:]) {
+		refs = append(refs, assetRef{name: strings.TrimLeft(base[:i]+ext, "/"), hash: base[i+1:]})
+	}
+	if len(ext) > 0 && assetHashReg.MatchString(ext[1:]) {
+		refs = append(refs, assetRef{name: strings.TrimLeft(base, "/"), hash: ext[1:]})
+	}
+	return refs
+}
+
+func hashAsset(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:assetHashLen]
+}
+
+// load fingerprint the file or the bundle
+func (ap *assetPipeline) load(name string) (*asset, error) {
+	if files, ok := ap.bundles[name]; ok {
+		buf := &bytes.Buffer{}
+		var modTime time.Time
+		for _, file := range files {
+			data, err := fs.ReadFile(ap.fsys, file)
+			if err != nil {
+				return nil, err
+			}
+			if stat, err := fs.Stat(ap.fsys, file); err == nil && stat.ModTime().After(modTime) {
+				modTime = stat.ModTime()
+			}
+			buf.Write(data)
+			buf.WriteByte('\n')
+		}
+		content := buf.Bytes()
+		if ap.opt.Minify {
+			content = minifyAsset(name, content)
+		}
+		hash := hashAsset(content)
+		return &asset{name: name, url: fingerprint(name, hash), hash: hash, modTime: modTime, content: content}, nil
+	}
+	stat, err := fs.Stat(ap.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if stat.IsDir() {
+		return nil, fs.ErrNotExist
+	}
+	data, err := fs.ReadFile(ap.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	hash := hashAsset(data)
+	return &asset{name: name, url: fingerprint(name, hash), hash: hash, modTime: stat.ModTime()}, nil
+}
+
+// get get the fingerprinted asset of the name
+func (ap *assetPipeline) get(name string) (*asset, error) {
+	name = strings.TrimLeft(ClearPath(name), "/")
+	ap.lock.RLock()
+	a, ok := ap.assets[name]
+	ap.lock.RUnlock()
+	if ok {
+		return a, nil
+	}
+	ap.lock.Lock()
+	defer ap.lock.Unlock()
+	if a, ok = ap.assets[name]; ok {
+		return a, nil
+	}
+	a, err := ap.load(name)
+	if err != nil {
+		return nil, err
+	}
+	ap.assets[name] = a
+	return a, nil
+}
+
+// clear clear the fingerprinted assets, the assets are fingerprinted again when they are used
+func (ap *assetPipeline) clear() {
+	ap.lock.Lock()
+	defer ap.lock.Unlock()
+	ap.assets = make(map[string]*asset)
+}
+
+// find find the asset of the url path. The fingerprinted url is only accepted if the hash is the fingerprint of
+// the current content, and the bundle can also be requested by the name. The hidden files are not found unless
+// they are allowed
+func (ap *assetPipeline) find(urlPath string, allowHidden bool) (*asset, bool) {
+	for _, ref := range unfingerprint(urlPath) {
+		if !allowHidden && isHidden(ref.name) {
+			continue
+		}
+		if a, err := ap.get(ref.name); err == nil && a.hash == ref.hash {
+			return a, true
+		}
+	}
+	name := strings.TrimLeft(urlPath, "/")
+	if _, isBundle := ap.bundles[name]; isBundle {
+		if a, err := ap.get(name); err == nil {
+			return a, false
+		}
+	}
+	return nil, false
+}
+
+// serve serve the fingerprinted asset or the bundle. It returns false if the request is not an asset request
+func (ap *assetPipeline) serve(w http.ResponseWriter, r *http.Request, allowHidden bool) bool {
+	a, fingerprinted := ap.find(ClearPath(r.URL.Path), allowHidden)
+	if a == nil {
+		return false
+	}
+	if fingerprinted {
+		maxAge := ap.opt.MaxAge
+		if maxAge <= 0 {
+			maxAge = 365 * 24 * time.Hour
+		}
+		w.Header().Set("Cache-Control", strAdd("public, max-age=", strconv.FormatInt(int64(maxAge/time.Second), 10), ", immutable"))
+	} else {
+		// the bundle requested by the name is not cached, because the content may be changed
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.Header().Set("ETag", quoteETag(a.hash))
+	if a.content != nil {
+		http.ServeContent(w, r, a.name, a.modTime, bytes.NewReader(a.content))
+		return true
+	}
+	f, err := ap.fsys.Open(a.name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return false
+		}
+		content = bytes.NewReader(data)
+	}
+	http.ServeContent(w, r, a.name, a.modTime, content)
+	return true
+}
+
+// assetHandler the fswatcher handler that clears the fingerprinted assets when the content files are changed
+type assetHandler struct {
+	pipeline *assetPipeline
+	root     string
+}
+
+func (ah *assetHandler) CanHandle(p string) bool {
+	return strings.HasPrefix(ClearPath(p), ah.root)
+}
+
+func (ah *assetHandler) Handle(ev *fsnotify.Event) {
+	if ev.Op&fsnotify.Create == fsnotify.Create {
+		if stat, err := os.Stat(ev.Name); err == nil && stat.IsDir() {
+			ah.pipeline.watcher.AddWatch(ev.Name, true)
+		}
+	}
+	ah.pipeline.clear()
+}
+
+var (
+	cssCommentReg = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssSpaceReg   = regexp.MustCompile(`\s+`)
+	// the white spaces around ':' and '>' are kept, because they are significant in the selectors like 'div :first-child'
+	cssPunctReg = regexp.MustCompile(`\s*([{};,])\s*`)
+)
+
+// minifyAsset minify the CSS or the JS content conservatively
+func minifyAsset(name string, content []byte) []byte {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".css":
+		content = cssCommentReg.ReplaceAll(content, nil)
+		content = cssSpaceReg.ReplaceAll(content, []byte(" "))
+		content = cssPunctReg.ReplaceAll(content, []byte("$1"))
+		return bytes.TrimSpace(content)
+	case ".js":
+		return minifyJS(content)
+	}
+	return content
+}
+
+// EnableAssets enable the asset pipeline. The view function 'asset' gets the fingerprinted url of the file in the
+// content root, for example {{asset "css/site.css"}} outputs '/css/site.0123456789.css', and the fingerprinted
+// urls are served with the immutable 'Cache-Control' header. The fingerprints are regenerated when the files
+// are changed. If the asset pipeline is not enabled, the view function 'asset' outputs the file url
+func (s *Server) EnableAssets(opt *AssetOption) {
+	s.assertUnlocked()
+	ap := &assetPipeline{
+		assets:  make(map[string]*asset),
+		bundles: make(map[string][]string),
+	}
+	if opt != nil {
+		ap.opt = *opt
+	}
+	if s.webFS != nil {
+		ap.fsys = s.subFS("www")
+	} else {
+		ap.fsys = os.DirFS(s.contentRoot)
+		w, err := fswatcher.NewWatcher()
+		assert.PanicErr(err)
+		ap.watcher = w
+		w.AddHandler(&assetHandler{pipeline: ap, root: ClearPath(s.contentRoot)})
+		w.Start()
+		if isDir(s.contentRoot) {
+			w.AddWatch(s.contentRoot, true)
+		}
+	}
+	if s.assets != nil && s.assets.watcher != nil {
+		s.assets.watcher.Stop()
+	}
+	s.assets = ap
+}
+
+// AssetBundle add the bundle 'name' that concatenates the files in the content root, for example
+// AssetBundle("js/site.js", "js/jquery.js", "js/app.js"). The bundle is fingerprinted like the files
+func (s *Server) AssetBundle(name string, files ...string) {
+	s.assertUnlocked()
+	assert.NotEmpty("name", name)
+	assert.Assert("files", func() bool {
+		return len(files) > 0
+	})
+	assert.Assert("s.assets", func() bool {
+		return s.assets != nil
+	})
+	var bundle []string
+	for _, file := range files {
+		bundle = append(bundle, strings.TrimLeft(ClearPath(file), "/"))
+	}
+	s.assets.bundles[strings.TrimLeft(ClearPath(name), "/")] = bundle
+}
+
+// AssetURL get the fingerprinted url of the file or the bundle in the content root. It returns the file url
+// if the asset pipeline is not enabled or the file does not exist
+func (s *Server) AssetURL(name string) string {
+	if s.assets != nil {
+		if a, err := s.assets.get(name); err == nil {
+			return a.url
+		}
+	}
+	return EnsurePrefix(ClearPath(name), "/")
+}
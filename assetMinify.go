@@ -0,0 +1,183 @@
+package mego
+
+import (
+	"bytes"
+	"strings"
+)
+
+// regexKeywords the keywords that can be followed by a regular expression literal
+var regexKeywords = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true, "new": true, "delete": true,
+	"void": true, "throw": true, "case": true, "do": true, "else": true, "yield": true, "await": true,
+}
+
+// jsMinifier the conservative JS minifier. It removes the comments and the blank lines, and keeps the strings, the
+// template literals and the regular expressions unchanged. The line breaks are kept, so the automatic semicolon
+// insertion is not affected
+type jsMinifier struct {
+	src []byte
+	out bytes.Buffer
+	// depth the depth of the braces
+	depth int
+	// templates the brace depths of the template literals whose substitutions ('${...}') are being read
+	templates []int
+	// lineStart the position in the output where the current line of the code starts
+	lineStart int
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// regexAllowed check if the '/' starts a regular expression by the previous token of the output
+func (m *jsMinifier) regexAllowed() bool {
+	code := bytes.TrimRight(m.out.Bytes(), " \t\r\n")
+	if len(code) == 0 {
+		return true
+	}
+	last := code[len(code)-1]
+	if strings.IndexByte("(,=:[!&|?{};+-*%<>~^", last) >= 0 {
+		return true
+	}
+	i := len(code)
+	for i > 0 && isIdentByte(code[i-1]) {
+		i--
+	}
+	return regexKeywords[string(code[i:])]
+}
+
+// quoted write the string or the regular expression that starts at i and ends with the unescaped quote. The
+// quote in the character class of the regular expression does not end it. It returns the position after the end
+func (m *jsMinifier) quoted(i int, quote byte) int {
+	j := i + 1
+	inClass := false
+	for j < len(m.src) {
+		c := m.src[j]
+		if c == '\\' {
+			j += 2
+			continue
+		}
+		if c == '\n' {
+			// the unterminated string or regular expression
+			break
+		}
+		j++
+		if quote == '/' && c == '[' {
+			inClass = true
+		} else if quote == '/' && c == ']' {
+			inClass = false
+		} else if c == quote && !inClass {
+			break
+		}
+	}
+	if j > len(m.src) {
+		j = len(m.src)
+	}
+	m.out.Write(m.src[i:j])
+	return j
+}
+
+// template write the template literal from i to the closing backtick or the next substitution, and return the
+// position after it
+func (m *jsMinifier) template(i int) int {
+	// the backtick or the brace that closes the substitution
+	j := i + 1
+	for j < len(m.src) {
+		c := m.src[j]
+		if c == '\\' {
+			j += 2
+			continue
+		}
+		if c == '`' {
+			j++
+			break
+		}
+		if c == '$' && j+1 < len(m.src) && m.src[j+1] == '{' {
+			j += 2
+			m.templates = append(m.templates, m.depth)
+			m.depth++
+			break
+		}
+		j++
+	}
+	if j > len(m.src) {
+		j = len(m.src)
+	}
+	m.out.Write(m.src[i:j])
+	return j
+}
+
+// newline write the line break of the code, the blank line is removed
+func (m *jsMinifier) newline() {
+	if len(bytes.TrimSpace(m.out.Bytes()[m.lineStart:])) == 0 {
+		m.out.Truncate(m.lineStart)
+		return
+	}
+	m.out.WriteByte('\n')
+	m.lineStart = m.out.Len()
+}
+
+func (m *jsMinifier) minify() []byte {
+	src := m.src
+	for i := 0; i < len(src); {
+		c := src[i]
+		var next byte
+		if i+1 < len(src) {
+			next = src[i+1]
+		}
+		switch {
+		case c == '"' || c == '\'':
+			i = m.quoted(i, c)
+		case c == '`':
+			i = m.template(i)
+		case c == '/' && next == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '/' && next == '*' && !bytes.HasPrefix(src[i:], []byte("/*!")):
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(src)
+			} else {
+				end += i + 4
+			}
+			// the comment is replaced by the separator, so the tokens are not joined
+			if bytes.IndexByte(src[i:end], '\n') >= 0 {
+				m.newline()
+			} else {
+				m.out.WriteByte(' ')
+			}
+			i = end
+		case c == '/' && m.regexAllowed():
+			i = m.quoted(i, '/')
+		case c == '\n':
+			m.newline()
+			i++
+		case c == '{':
+			m.depth++
+			m.out.WriteByte(c)
+			i++
+		case c == '}':
+			m.depth--
+			if n := len(m.templates); n > 0 && m.templates[n-1] == m.depth {
+				m.templates = m.templates[:n-1]
+				i = m.template(i)
+				continue
+			}
+			m.out.WriteByte(c)
+			i++
+		default:
+			m.out.WriteByte(c)
+			i++
+		}
+	}
+	m.newline()
+	return bytes.TrimRight(m.out.Bytes(), "\n")
+}
+
+// minifyJS minify the JS content conservatively: the comments and the blank lines are removed. The license
+// comments that start with '/*!' are kept
+func minifyJS(content []byte) []byte {
+	m := &jsMinifier{src: content}
+	return m.minify()
+}
@@ -0,0 +1,22 @@
+package mego
+
+import "testing"
+
+func TestMinifyJS(t *testing.T) {
+	cases := []struct {
+		src, expected string
+	}{
+		{"// comment\nvar a = 1; // trailing\n\n\nvar b = 2;\n", "var a = 1; \nvar b = 2;"},
+		{"var a = 1 /* inline */ + 2;\n/*\n * block\n */\nvar b;", "var a = 1   + 2;\nvar b;"},
+		{"/*! license */\nvar a;", "/*! license */\nvar a;"},
+		{`var s = "http://example.com/*"; var t = '//';`, `var s = "http://example.com/*"; var t = '//';`},
+		{"var t = `line\n\n// kept ${a + `/* ${b} */`} ${ {c: 1}.c }\n`; // removed", "var t = `line\n\n// kept ${a + `/* ${b} */`} ${ {c: 1}.c }\n`; "},
+		{"var r = /\\/\\/[/*]\"/g.test(x); return /'/;", "var r = /\\/\\/[/*]\"/g.test(x); return /'/;"},
+		{"var d = a / b / c; // div", "var d = a / b / c; "},
+	}
+	for _, c := range cases {
+		if actual := string(minifyJS([]byte(c.src))); actual != c.expected {
+			t.Errorf("minifyJS(%q) = %q, expected %q", c.src, actual, c.expected)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/simbory/mego"
+	"github.com/simbory/mego/assert"
+	"github.com/simbory/mego/session"
+)
+
+// User the default implementation of the mego.Principal interface
+type User struct {
+	UserName string
+	Roles    []string
+	Claims   map[string]interface{}
+}
+
+// Name get the name of the user
+func (u *User) Name() string {
+	return u.UserName
+}
+
+// IsInRole check if the user is in the role
+func (u *User) IsInRole(role string) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Scheme the authentication scheme interface
+type Scheme interface {
+	// Authenticate authenticate the request. It returns nil if the request does not carry the credentials of the scheme,
+	// or an error if the credentials are invalid
+	Authenticate(ctx *mego.HttpCtx) (mego.Principal, error)
+	// Challenge write the challenge of the scheme (like the 'WWW-Authenticate' header) to the unauthorized response
+	Challenge(ctx *mego.HttpCtx)
+}
+
+// Target the server or area that can be guarded by the authenticator
+type Target interface {
+	HijackRequest(pathPrefix string, h func(*mego.HttpCtx))
+}
+
+// Authenticator the authenticator that authenticates the requests by the schemes in order
+type Authenticator struct {
+	schemes []Scheme
+	// LoginURL the login page that the unauthenticated HTML clients are redirected to. The requested URL is
+	// appended as the query string 'returnUrl'. The status code 401 is sent to all the clients if it's empty
+	LoginURL string
+}
+
+// Authenticate authenticate the request by the schemes and set the authenticated user of the context.
+// It returns nil if the request is not authenticated
+func (a *Authenticator) Authenticate(ctx *mego.HttpCtx) mego.Principal {
+	if user := ctx.User(); user != nil {
+		return user
+	}
+	for _, scheme := range a.schemes {
+		user, err := scheme.Authenticate(ctx)
+		if err != nil {
+			return nil
+		}
+		if user != nil {
+			ctx.SetUser(user)
+			return user
+		}
+	}
+	return nil
+}
+
+// RequireAuth get the hijack handler that only allows the authenticated requests
+func (a *Authenticator) RequireAuth() func(*mego.HttpCtx) {
+	return a.RequireRole()
+}
+
+// RequireRole get the hijack handler that only allows the authenticated users in any of the roles.
+// The unauthenticated HTML clients are redirected to the login page, and the status code 401 is sent to the
+// other clients. The authenticated users that are not in the roles get the status code 403
+func (a *Authenticator) RequireRole(roles ...string) func(*mego.HttpCtx) {
+	return func(ctx *mego.HttpCtx) {
+		user := a.Authenticate(ctx)
+		if user == nil {
+			a.unauthorized(ctx)
+			return
+		}
+		if len(roles) == 0 {
+			return
+		}
+		for _, role := range roles {
+			if user.IsInRole(role) {
+				return
+			}
+		}
+		ctx.Forbidden()
+	}
+}
+
+// Guard guard the dynamic requests of the target that start with pathPrefix. Only the authenticated users
+// (in any of the roles if the roles are not empty) are allowed
+func (a *Authenticator) Guard(target Target, pathPrefix string, roles ...string) {
+	assert.NotNil("target", target)
+	target.HijackRequest(pathPrefix, a.RequireRole(roles...))
+}
+
+func (a *Authenticator) unauthorized(ctx *mego.HttpCtx) {
+	r := ctx.Request()
+	if len(a.LoginURL) > 0 && (r.Method == "GET" || r.Method == "HEAD") && acceptsHTML(r) {
+		loginURL := a.LoginURL
+		if strings.Contains(loginURL, "?") {
+			loginURL += "&"
+		} else {
+			loginURL += "?"
+		}
+		ctx.Redirect(loginURL+"returnUrl="+url.QueryEscape(r.URL.RequestURI()), false)
+		return
+	}
+	for _, scheme := range a.schemes {
+		scheme.Challenge(ctx)
+	}
+	w := ctx.Response()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write([]byte(http.StatusText(http.StatusUnauthorized)))
+	ctx.End()
+}
+
+// acceptsHTML check if the client accepts the HTML response
+func acceptsHTML(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "text/html") || strings.Contains(accept, "application/xhtml+xml")
+}
+
+// New create a new authenticator with the schemes
+func New(schemes ...Scheme) *Authenticator {
+	for _, scheme := range schemes {
+		assert.NotNil("scheme", scheme)
+	}
+	return &Authenticator{schemes: schemes}
+}
+
+func init() {
+	session.RegisterType(&User{})
+}
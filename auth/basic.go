@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/simbory/mego"
+	"github.com/simbory/mego/assert"
+)
+
+// ErrInvalidCredentials the error returned by the schemes if the credentials are invalid
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// CredentialFunc validate the user name and the password and get the user. It returns nil if the credentials are invalid
+type CredentialFunc func(userName, password string) mego.Principal
+
+// basicScheme the HTTP basic authentication scheme
+type basicScheme struct {
+	realm    string
+	validate CredentialFunc
+}
+
+// Authenticate authenticate the request by the 'Authorization: Basic' header
+func (bs *basicScheme) Authenticate(ctx *mego.HttpCtx) (mego.Principal, error) {
+	userName, password, ok := ctx.Request().BasicAuth()
+	if !ok {
+		return nil, nil
+	}
+	user := bs.validate(userName, password)
+	if user == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// Challenge write the 'WWW-Authenticate: Basic' header
+func (bs *basicScheme) Challenge(ctx *mego.HttpCtx) {
+	ctx.Response().Header().Add("WWW-Authenticate", "Basic realm="+strconv.Quote(bs.realm)+`, charset="UTF-8"`)
+}
+
+// Basic create the HTTP basic authentication scheme with the realm and the credential callback
+func Basic(realm string, validate CredentialFunc) Scheme {
+	assert.NotNil("validate", validate)
+	if len(realm) == 0 {
+		realm = "mego"
+	}
+	return &basicScheme{realm: realm, validate: validate}
+}
@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simbory/mego"
+	"github.com/simbory/mego/assert"
+)
+
+// jwtKey the key to verify the JWT signature
+type jwtKey struct {
+	alg    string
+	secret []byte
+	public *rsa.PublicKey
+}
+
+// KeySet the JWT verification keys identified by the key IDs ('kid' header). The keys can be added and
+// removed at any time to rotate the keys
+type KeySet struct {
+	lock sync.RWMutex
+	keys map[string]*jwtKey
+}
+
+func (ks *KeySet) add(kid string, key *jwtKey) {
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	if ks.keys == nil {
+		ks.keys = make(map[string]*jwtKey)
+	}
+	ks.keys[kid] = key
+}
+
+// AddHS256 add the HMAC-SHA256 secret with the key ID
+func (ks *KeySet) AddHS256(kid string, secret []byte) {
+	assert.Assert("secret", func() bool {
+		return len(secret) > 0
+	})
+	ks.add(kid, &jwtKey{alg: "HS256", secret: secret})
+}
+
+// AddRS256 add the RSA-SHA256 public key with the key ID
+func (ks *KeySet) AddRS256(kid string, key *rsa.PublicKey) {
+	assert.NotNil("key", key)
+	ks.add(kid, &jwtKey{alg: "RS256", public: key})
+}
+
+// Remove remove the key with the key ID
+func (ks *KeySet) Remove(kid string) {
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	delete(ks.keys, kid)
+}
+
+// candidates get the keys that can verify the token with the algorithm and the key ID. all the keys of
+// the algorithm are returned if the token has no key ID
+func (ks *KeySet) candidates(alg, kid string) []*jwtKey {
+	ks.lock.RLock()
+	defer ks.lock.RUnlock()
+	if len(kid) > 0 {
+		if key, ok := ks.keys[kid]; ok && key.alg == alg {
+			return []*jwtKey{key}
+		}
+		return nil
+	}
+	var keys []*jwtKey
+	for _, key := range ks.keys {
+		if key.alg == alg {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// verify verify the signature of the token and get the claims
+func (ks *KeySet) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("jwt: malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("jwt: malformed signature: %s", err.Error())
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range ks.candidates(header.Alg, header.Kid) {
+		switch key.alg {
+		case "HS256":
+			mac := hmac.New(sha256.New, key.secret)
+			mac.Write(signed)
+			verified = hmac.Equal(sig, mac.Sum(nil))
+		case "RS256":
+			sum := sha256.Sum256(signed)
+			verified = rsa.VerifyPKCS1v15(key.public, crypto.SHA256, sum[:], sig) == nil
+		}
+		if verified {
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("jwt: invalid signature")
+	}
+	claims := make(map[string]interface{})
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return fmt.Errorf("jwt: malformed segment: %s", err.Error())
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("jwt: malformed segment: %s", err.Error())
+	}
+	return nil
+}
+
+// JWT the bearer JWT authentication scheme. The HS256 and RS256 algorithms are supported
+type JWT struct {
+	// Keys the verification keys
+	Keys *KeySet
+	// Issuer the expected 'iss' claim. It's not checked if it's empty
+	Issuer string
+	// Audience the expected 'aud' claim. It's not checked if it's empty
+	Audience string
+	// Leeway the clock skew allowed when checking the 'exp' and 'nbf' claims
+	Leeway time.Duration
+	// NameClaim the claim of the user name, the default value is 'sub'
+	NameClaim string
+	// RolesClaim the claim of the user roles, the default value is 'roles'
+	RolesClaim string
+	// Realm the realm of the 'WWW-Authenticate' challenge
+	Realm string
+}
+
+// Authenticate authenticate the request by the 'Authorization: Bearer' header
+func (j *JWT) Authenticate(ctx *mego.HttpCtx) (mego.Principal, error) {
+	header := ctx.Request().Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "bearer ") {
+		return nil, nil
+	}
+	if j.Keys == nil {
+		return nil, ErrInvalidCredentials
+	}
+	claims, err := j.Keys.verify(strings.TrimSpace(header[7:]))
+	if err != nil {
+		return nil, err
+	}
+	if err = j.validate(claims); err != nil {
+		return nil, err
+	}
+	nameClaim := j.NameClaim
+	if len(nameClaim) == 0 {
+		nameClaim = "sub"
+	}
+	rolesClaim := j.RolesClaim
+	if len(rolesClaim) == 0 {
+		rolesClaim = "roles"
+	}
+	user := &User{Claims: claims}
+	user.UserName, _ = claims[nameClaim].(string)
+	switch roles := claims[rolesClaim].(type) {
+	case string:
+		user.Roles = strings.Fields(roles)
+	case []interface{}:
+		for _, role := range roles {
+			if str, ok := role.(string); ok {
+				user.Roles = append(user.Roles, str)
+			}
+		}
+	}
+	return user, nil
+}
+
+// validate validate the registered claims
+func (j *JWT) validate(claims map[string]interface{}) error {
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(j.Leeway)) {
+		return errors.New("jwt: token is expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0).Add(-j.Leeway)) {
+		return errors.New("jwt: token is not valid yet")
+	}
+	if len(j.Issuer) > 0 && claims["iss"] != j.Issuer {
+		return errors.New("jwt: invalid issuer")
+	}
+	if len(j.Audience) > 0 {
+		found := false
+		switch aud := claims["aud"].(type) {
+		case string:
+			found = aud == j.Audience
+		case []interface{}:
+			for _, a := range aud {
+				if a == j.Audience {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			return errors.New("jwt: invalid audience")
+		}
+	}
+	return nil
+}
+
+// Challenge write the 'WWW-Authenticate: Bearer' header
+func (j *JWT) Challenge(ctx *mego.HttpCtx) {
+	challenge := "Bearer"
+	if len(j.Realm) > 0 {
+		challenge = challenge + ` realm="` + j.Realm + `"`
+	}
+	ctx.Response().Header().Add("WWW-Authenticate", challenge)
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"github.com/simbory/mego"
+	"github.com/simbory/mego/assert"
+	"github.com/simbory/mego/session"
+)
+
+// SessionScheme the session (cookie) login authentication scheme
+type SessionScheme struct {
+	manager *session.Manager
+	key     string
+}
+
+// Authenticate authenticate the request by the user stored in the session
+func (ss *SessionScheme) Authenticate(ctx *mego.HttpCtx) (mego.Principal, error) {
+	store := ss.manager.Start(ctx)
+	if store == nil {
+		return nil, nil
+	}
+	user, _ := store.Get(ss.key).(mego.Principal)
+	return user, nil
+}
+
+// Challenge do nothing, the session scheme has no challenge
+func (ss *SessionScheme) Challenge(ctx *mego.HttpCtx) {
+}
+
+// SignIn store the user in the session and set the authenticated user of the context.
+// The session ID is regenerated to prevent the session fixation
+func (ss *SessionScheme) SignIn(ctx *mego.HttpCtx, user mego.Principal) error {
+	assert.NotNil("user", user)
+	store := ss.manager.RegenerateID(ctx)
+	if store == nil {
+		return ErrInvalidCredentials
+	}
+	if err := store.Set(ss.key, user); err != nil {
+		return err
+	}
+	ctx.SetUser(user)
+	return nil
+}
+
+// SignOut remove the user from the session
+func (ss *SessionScheme) SignOut(ctx *mego.HttpCtx) error {
+	ctx.SetUser(nil)
+	store := ss.manager.Start(ctx)
+	if store == nil {
+		return nil
+	}
+	return store.Delete(ss.key)
+}
+
+// Session create the session login authentication scheme. The user is stored in the session with the key
+func Session(manager *session.Manager, key string) *SessionScheme {
+	assert.NotNil("manager", manager)
+	if len(key) == 0 {
+		key = "__auth_user"
+	}
+	return &SessionScheme{manager: manager, key: key}
+}
@@ -85,6 +85,8 @@ func (c *Manager) Set(name string, data interface{}, dependencyFiles []string, e
 
 // Get get the data by name
 func (c *Manager) Get(name string) interface{} {
+	c.locker.RLock()
+	defer c.locker.RUnlock()
 	if c.dataMap == nil {
 		return nil
 	}
@@ -130,13 +132,13 @@ func (c *Manager) Remove(name string) {
 
 func (c *Manager) gc() {
 	var now = time.Now()
+	c.locker.Lock()
 	for name, data := range c.dataMap {
-		if now.Before(data.expire) {
-			c.locker.Lock()
+		if !now.Before(data.expire) {
 			delete(c.dataMap, name)
-			c.locker.Unlock()
 		}
 	}
+	c.locker.Unlock()
 	c.timer = time.AfterFunc(c.gcInterval, func() {
 		c.gc()
 	})
@@ -35,5 +35,5 @@ func (cd *fileHandler) Handle(ev *fsnotify.Event) {
 		cd.cacheManager.dataMap[key] = nil
 		delete(cd.cacheManager.dataMap, key)
 	}
-	cd.cacheManager.locker.Lock()
+	cd.cacheManager.locker.Unlock()
 }
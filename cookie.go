@@ -0,0 +1,225 @@
+package mego
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/simbory/mego/assert"
+)
+
+// cookieKey the keys derived from one key of the key ring
+type cookieKey struct {
+	sign []byte
+	aead cipher.AEAD
+}
+
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+func newCookieKey(key []byte) *cookieKey {
+	block, err := aes.NewCipher(deriveKey(key, "mego-cookie-encrypt"))
+	assert.PanicErr(err)
+	aead, err := cipher.NewGCM(block)
+	assert.PanicErr(err)
+	return &cookieKey{sign: deriveKey(key, "mego-cookie-sign"), aead: aead}
+}
+
+// KeyRing the key ring to sign and encrypt the values. The first key is used to sign and encrypt the
+// values, and all the keys are used to verify and decrypt the values, so that the keys can be rotated
+type KeyRing struct {
+	keys []*cookieKey
+}
+
+func (kr *KeyRing) mac(key *cookieKey, name, value string) []byte {
+	mac := hmac.New(sha256.New, key.sign)
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// Sign sign the value with the name, the name must be the same when the value is verified
+func (kr *KeyRing) Sign(name, value string) string {
+	sig := kr.mac(kr.keys[0], name, value)
+	return strAdd(base64.RawURLEncoding.EncodeToString(str2Byte(value)), ".", base64.RawURLEncoding.EncodeToString(sig))
+}
+
+// Verify verify the signed value with the name and get the original value
+func (kr *KeyRing) Verify(name, signed string) (string, bool) {
+	i := strings.LastIndexByte(signed, '.')
+	if i < 0 {
+		return "", false
+	}
+	value, err := base64.RawURLEncoding.DecodeString(signed[:i])
+	if err != nil {
+		return "", false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signed[i+1:])
+	if err != nil {
+		return "", false
+	}
+	for _, key := range kr.keys {
+		if hmac.Equal(sig, kr.mac(key, name, byte2Str(value))) {
+			return string(value), true
+		}
+	}
+	return "", false
+}
+
+// Encrypt encrypt and authenticate the value with AES-GCM, the name must be the same when the value is decrypted
+func (kr *KeyRing) Encrypt(name string, value []byte) string {
+	key := kr.keys[0]
+	nonce := make([]byte, key.aead.NonceSize(), key.aead.NonceSize()+len(value)+key.aead.Overhead())
+	_, err := rand.Read(nonce)
+	assert.PanicErr(err)
+	sealed := key.aead.Seal(nonce, nonce, value, str2Byte(name))
+	return base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+// Decrypt decrypt the encrypted value with the name and get the original value
+func (kr *KeyRing) Decrypt(name, encrypted string) ([]byte, bool) {
+	sealed, err := base64.RawURLEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, false
+	}
+	for _, key := range kr.keys {
+		size := key.aead.NonceSize()
+		if len(sealed) < size {
+			continue
+		}
+		value, err := key.aead.Open(nil, sealed[:size], sealed[size:], str2Byte(name))
+		if err == nil {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// NewKeyRing create a new key ring. The first key is the current key, and the others are the old keys
+// that are still accepted. The keys should be at least 32 bytes of random data
+func NewKeyRing(keys ...[]byte) *KeyRing {
+	assert.Assert("keys", func() bool {
+		return len(keys) > 0
+	})
+	kr := &KeyRing{}
+	for _, key := range keys {
+		assert.Assert("keys", func() bool {
+			return len(key) > 0
+		})
+		kr.keys = append(kr.keys, newCookieKey(key))
+	}
+	return kr
+}
+
+// SetCookieKeys set the keys of the server key ring that signs and encrypts the cookies. The first key is the current
+// key, and the others are the old keys that are still accepted.
+func (s *Server) SetCookieKeys(keys ...[]byte) {
+	s.assertUnlocked()
+	s.keyRing = NewKeyRing(keys...)
+	s.cookieKeys = true
+}
+
+// HasCookieKeys check if the keys of the server key ring are set by SetCookieKeys
+func (s *Server) HasCookieKeys() bool {
+	return s.cookieKeys
+}
+
+// KeyRing get the key ring of the server. If the keys are not set by SetCookieKeys, a random key is generated,
+// and the signed or encrypted cookies become invalid after the server is restarted
+func (s *Server) KeyRing() *KeyRing {
+	s.keyRingLock.Lock()
+	defer s.keyRingLock.Unlock()
+	if s.keyRing == nil {
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		assert.PanicErr(err)
+		s.keyRing = NewKeyRing(key)
+	}
+	return s.keyRing
+}
+
+// Cookie get the request cookie by name. It returns nil if the cookie does not exist
+func (ctx *HttpCtx) Cookie(name string) *http.Cookie {
+	cookie, err := ctx.req.Cookie(name)
+	if err != nil {
+		return nil
+	}
+	return cookie
+}
+
+// SetCookie add the 'Set-Cookie' header to the response. The default path is '/', the default SameSite
+// mode is 'Lax', and the cookie is always secure if the SameSite mode is 'None'
+func (ctx *HttpCtx) SetCookie(cookie *http.Cookie) {
+	assert.NotNil("cookie", cookie)
+	assert.NotEmpty("cookie.Name", cookie.Name)
+	if len(cookie.Path) == 0 {
+		cookie.Path = "/"
+	}
+	if cookie.SameSite == http.SameSiteDefaultMode {
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+	if cookie.SameSite == http.SameSiteNoneMode {
+		cookie.Secure = true
+	}
+	http.SetCookie(ctx.res, cookie)
+}
+
+// DeleteCookie delete the cookie from the client
+func (ctx *HttpCtx) DeleteCookie(name, path string) {
+	ctx.SetCookie(&http.Cookie{
+		Name:    name,
+		Path:    path,
+		MaxAge:  -1,
+		Expires: time.Unix(0, 0),
+	})
+}
+
+// SignedCookie get the value of the cookie that is signed by SetSignedCookie.
+// It returns false if the cookie does not exist or it's tampered
+func (ctx *HttpCtx) SignedCookie(name string) (string, bool) {
+	cookie := ctx.Cookie(name)
+	if cookie == nil {
+		return "", false
+	}
+	return ctx.Server.KeyRing().Verify(name, cookie.Value)
+}
+
+// SetSignedCookie sign the cookie value with HMAC-SHA256 by the server key ring and then set the cookie
+func (ctx *HttpCtx) SetSignedCookie(cookie *http.Cookie) {
+	assert.NotNil("cookie", cookie)
+	signed := *cookie
+	signed.Value = ctx.Server.KeyRing().Sign(cookie.Name, cookie.Value)
+	ctx.SetCookie(&signed)
+}
+
+// EncryptedCookie get the value of the cookie that is encrypted by SetEncryptedCookie.
+// It returns false if the cookie does not exist or it cannot be decrypted
+func (ctx *HttpCtx) EncryptedCookie(name string) (string, bool) {
+	cookie := ctx.Cookie(name)
+	if cookie == nil {
+		return "", false
+	}
+	value, ok := ctx.Server.KeyRing().Decrypt(name, cookie.Value)
+	if !ok {
+		return "", false
+	}
+	return string(value), true
+}
+
+// SetEncryptedCookie encrypt the cookie value with AES-GCM by the server key ring and then set the cookie
+func (ctx *HttpCtx) SetEncryptedCookie(cookie *http.Cookie) {
+	assert.NotNil("cookie", cookie)
+	encrypted := *cookie
+	encrypted.Value = ctx.Server.KeyRing().Encrypt(cookie.Name, []byte(cookie.Value))
+	ctx.SetCookie(&encrypted)
+}
@@ -0,0 +1,192 @@
+package mego
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simbory/mego/assert"
+)
+
+// CorsOption the CORS (cross-origin resource sharing) option
+type CorsOption struct {
+	// AllowedOrigins the allowed origins. "*" means all the origins, and the wildcard patterns like
+	// "https://*.example.com" are supported
+	AllowedOrigins []string
+	// AllowedMethods the allowed methods of the cross-origin requests, the default methods are GET, HEAD and POST
+	AllowedMethods []string
+	// AllowedHeaders the allowed request headers. the headers requested by the preflight request are allowed if it's empty
+	AllowedHeaders []string
+	// ExposedHeaders the response headers that the client is allowed to access
+	ExposedHeaders []string
+	// AllowCredentials indicates whether the request can include user credentials like cookies. It can not be used
+	// with the "*" origin, the allowed origins must be listed explicitly
+	AllowCredentials bool
+	// MaxAge indicates how long the results of a preflight request can be cached
+	MaxAge time.Duration
+}
+
+// corsPolicy the compiled CORS option
+type corsPolicy struct {
+	opt       *CorsOption
+	anyOrigin bool
+	origins   []string
+	methods   []string
+	headers   []string
+}
+
+func newCorsPolicy(opt *CorsOption) *corsPolicy {
+	policy := &corsPolicy{opt: opt}
+	for _, origin := range opt.AllowedOrigins {
+		if origin == "*" {
+			policy.anyOrigin = true
+		}
+		policy.origins = append(policy.origins, strings.ToLower(origin))
+	}
+	// any site could read the credentialed responses if all the origins are allowed with the credentials
+	assert.Assert("opt.AllowedOrigins", func() bool {
+		return !policy.anyOrigin || !opt.AllowCredentials
+	})
+	for _, method := range opt.AllowedMethods {
+		policy.methods = append(policy.methods, strings.ToUpper(method))
+	}
+	if len(policy.methods) == 0 {
+		policy.methods = []string{"GET", "HEAD", "POST"}
+	}
+	for _, header := range opt.AllowedHeaders {
+		policy.headers = append(policy.headers, http.CanonicalHeaderKey(header))
+	}
+	return policy
+}
+
+// allowOrigin check if the origin is allowed
+func (policy *corsPolicy) allowOrigin(origin string) bool {
+	if policy.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, pattern := range policy.origins {
+		if pattern == origin {
+			return true
+		}
+		if matched, err := path.Match(pattern, origin); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// allowMethod check if the method is allowed
+func (policy *corsPolicy) allowMethod(method string) bool {
+	method = strings.ToUpper(method)
+	for _, m := range policy.methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// allowHeaders check if all the requested headers are allowed
+func (policy *corsPolicy) allowHeaders(requested []string) bool {
+	if len(policy.headers) == 0 {
+		return true
+	}
+	for _, header := range requested {
+		header = http.CanonicalHeaderKey(header)
+		found := false
+		for _, h := range policy.headers {
+			if h == header || h == "*" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// writeOrigin write the allowed origin and credential headers to the response
+func (policy *corsPolicy) writeOrigin(h http.Header, origin string) {
+	if policy.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if policy.opt.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// isPreflight check if the request is a CORS preflight request
+func isPreflight(r *http.Request) bool {
+	return r.Method == "OPTIONS" && len(r.Header.Get("Origin")) > 0 &&
+		len(r.Header.Get("Access-Control-Request-Method")) > 0
+}
+
+// preflight answer the preflight request. it returns false if the request is not allowed
+func (policy *corsPolicy) preflight(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	method := r.Header.Get("Access-Control-Request-Method")
+	var reqHeaders []string
+	for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if header = strings.TrimSpace(header); len(header) > 0 {
+			reqHeaders = append(reqHeaders, header)
+		}
+	}
+	if !policy.allowOrigin(origin) || !policy.allowMethod(method) || !policy.allowHeaders(reqHeaders) {
+		return false
+	}
+	h := w.Header()
+	policy.writeOrigin(h, origin)
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	h.Set("Access-Control-Allow-Methods", strings.Join(policy.methods, ", "))
+	if len(policy.headers) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(policy.headers, ", "))
+	} else if len(reqHeaders) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(reqHeaders, ", "))
+	}
+	if policy.opt.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.FormatInt(int64(policy.opt.MaxAge/time.Second), 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// actual write the CORS headers of the actual (non-preflight) request
+func (policy *corsPolicy) actual(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	// the response varies by the origin unless all the origins are allowed, even if the origin is not allowed
+	if !policy.anyOrigin {
+		h.Add("Vary", "Origin")
+	}
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 || !policy.allowOrigin(origin) {
+		return
+	}
+	policy.writeOrigin(h, origin)
+	if len(policy.opt.ExposedHeaders) > 0 {
+		h.Set("Access-Control-Expose-Headers", strings.Join(policy.opt.ExposedHeaders, ", "))
+	}
+}
+
+// EnableCors enable the CORS support of all the dynamic requests. the preflight requests
+// are answered automatically even if the route handler does not implement RouteOptions.
+func (s *Server) EnableCors(opt *CorsOption) {
+	s.assertUnlocked()
+	assert.NotNil("opt", opt)
+	s.cors = newCorsPolicy(opt)
+}
+
+// EnableCors enable the CORS support of the area dynamic requests. it overrides the CORS option of the server
+func (a *Area) EnableCors(opt *CorsOption) {
+	a.server.assertUnlocked()
+	assert.NotNil("opt", opt)
+	a.cors = newCorsPolicy(opt)
+}
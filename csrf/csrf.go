@@ -0,0 +1,174 @@
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/simbory/mego"
+	"github.com/simbory/mego/assert"
+	"github.com/simbory/mego/session"
+)
+
+// sessionKey the session key that the anti-forgery token is stored with
+const sessionKey = "__csrf_token"
+
+// Config the anti-forgery config
+type Config struct {
+	// Session the session manager that the tokens are stored in. The double-submit cookie is used if it's nil
+	Session *session.Manager
+	// FieldName the name of the hidden form field, the default value is '_csrf'
+	FieldName string
+	// HeaderName the name of the request header that carries the token, the default value is 'X-CSRF-Token'
+	HeaderName string
+	// CookieName the name of the double-submit cookie, the default value is 'CSRF_TOKEN'
+	CookieName string
+	// CookiePath the path of the double-submit cookie, the default value is '/'
+	CookiePath string
+	// Secure indicates whether the double-submit cookie is only sent over HTTPS
+	Secure bool
+}
+
+// Target the server or area that can be protected by the anti-forgery tokens
+type Target interface {
+	HijackRequest(pathPrefix string, h func(*mego.HttpCtx))
+	ExtendView(name string, f interface{})
+}
+
+// Protector the anti-forgery token issuer and validator
+type Protector struct {
+	config *Config
+}
+
+// ctxKey the context item key of the token issued in the current request
+func (p *Protector) ctxKey() string {
+	return "__csrf_token_" + p.config.FieldName
+}
+
+// storedToken get the token stored in the session or the double-submit cookie
+func (p *Protector) storedToken(ctx *mego.HttpCtx) string {
+	if p.config.Session != nil {
+		store := p.config.Session.Start(ctx)
+		if store == nil {
+			return ""
+		}
+		token, _ := store.Get(sessionKey).(string)
+		return token
+	}
+	cookie, err := ctx.Request().Cookie(p.config.CookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// Token get the anti-forgery token of the current request. A new token is issued if there is no one
+func (p *Protector) Token(ctx *mego.HttpCtx) string {
+	if token, ok := ctx.GetCtxItem(p.ctxKey()).(string); ok {
+		return token
+	}
+	token := p.storedToken(ctx)
+	if len(token) == 0 {
+		token = newToken()
+		if p.config.Session != nil {
+			store := p.config.Session.Start(ctx)
+			assert.NotNil("session", store)
+			assert.PanicErr(store.Set(sessionKey, token))
+		} else {
+			http.SetCookie(ctx.Response(), &http.Cookie{
+				Name:     p.config.CookieName,
+				Value:    token,
+				Path:     p.config.CookiePath,
+				Secure:   p.config.Secure,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+	}
+	ctx.SetCtxItem(p.ctxKey(), token)
+	return token
+}
+
+// Validate check if the token posted by the form field or the request header matches the stored token
+func (p *Protector) Validate(ctx *mego.HttpCtx) bool {
+	expected := p.storedToken(ctx)
+	if len(expected) == 0 {
+		return false
+	}
+	actual := ctx.Request().Header.Get(p.config.HeaderName)
+	if len(actual) == 0 {
+		actual = ctx.PostFormValue(p.config.FieldName)
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) == 1
+}
+
+// Field get the hidden form field that carries the anti-forgery token
+func (p *Protector) Field(ctx *mego.HttpCtx) template.HTML {
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(p.config.FieldName) +
+		`" value="` + template.HTMLEscapeString(p.Token(ctx)) + `"/>`)
+}
+
+// Protect protect the dynamic requests of the target that start with pathPrefix. The requests with the
+// unsafe methods must post a valid token, or the status code 403 is sent by the handler set by Server.Handle403.
+// The view functions 'csrfField' and 'csrfToken' are added to the view engine of the target.
+func (p *Protector) Protect(target Target, pathPrefix string) {
+	assert.NotNil("target", target)
+	target.ExtendView("csrfField", func() template.HTML { return "" })
+	target.ExtendView("csrfToken", func() string { return "" })
+	target.HijackRequest(pathPrefix, func(ctx *mego.HttpCtx) {
+		ctx.ExtendView("csrfField", func() template.HTML {
+			return p.Field(ctx)
+		})
+		ctx.ExtendView("csrfToken", func() string {
+			return p.Token(ctx)
+		})
+		if isSafeMethod(ctx.Request().Method) {
+			return
+		}
+		if !p.Validate(ctx) {
+			ctx.Forbidden()
+		}
+	})
+}
+
+// New create a new anti-forgery token protector
+func New(config *Config) *Protector {
+	if config == nil {
+		config = new(Config)
+	}
+	if len(config.FieldName) == 0 {
+		config.FieldName = "_csrf"
+	}
+	if len(config.HeaderName) == 0 {
+		config.HeaderName = "X-CSRF-Token"
+	}
+	if len(config.CookieName) == 0 {
+		config.CookieName = "CSRF_TOKEN"
+	}
+	if len(config.CookiePath) == 0 {
+		config.CookiePath = "/"
+	}
+	if config.Session == nil {
+		// the pages that carry the token of the double-submit cookie are not served from the output cache
+		mego.RegisterPrivateCookie(config.CookieName)
+	}
+	return &Protector{config: config}
+}
+
+func isSafeMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	}
+	return false
+}
+
+func newToken() string {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	assert.PanicErr(err)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
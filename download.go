@@ -0,0 +1,231 @@
+package mego
+
+import (
+	"archive/zip"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/simbory/mego/assert"
+)
+
+// contentDisposition get the 'Content-Disposition' header of the file name (RFC 6266). The 'filename'
+// parameter is the ASCII fallback of the file name, and the 'filename*' parameter is the UTF-8 file name
+func contentDisposition(dispType, fileName string) string {
+	if len(fileName) == 0 {
+		return dispType
+	}
+	fallback := strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' || r == '%' {
+			return '_'
+		}
+		return r
+	}, fileName)
+	value := strAdd(dispType, "; filename=\"", fallback, "\"")
+	if fallback != fileName {
+		value = strAdd(value, "; filename*=UTF-8''", encodeExtValue(fileName))
+	}
+	return value
+}
+
+// encodeExtValue percent-encode the value of the extended parameter (RFC 8187)
+func encodeExtValue(s string) string {
+	const hex = "0123456789ABCDEF"
+	buf := make([]byte, 0, len(s)*3)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			buf = append(buf, c)
+			continue
+		}
+		buf = append(buf, '%', hex[c>>4], hex[c&15])
+	}
+	return string(buf)
+}
+
+// DownloadResult the result that sends the content as the downloaded file. The range requests and the
+// conditional requests are supported
+type DownloadResult struct {
+	// FileName the file name that is saved by the client
+	FileName string
+	Content  io.ReadSeeker
+	ModTime  time.Time
+	// ContentType the content type, it's detected by the file extension by default
+	ContentType string
+	// Inline display the content in the browser instead of downloading it
+	Inline bool
+}
+
+// ExecResult send the content with the 'Content-Disposition' header. The content is closed if it's an io.Closer
+func (dr *DownloadResult) ExecResult(w http.ResponseWriter, r *http.Request) {
+	if closer, ok := dr.Content.(io.Closer); ok {
+		defer closer.Close()
+	}
+	dispType := "attachment"
+	if dr.Inline {
+		dispType = "inline"
+	}
+	w.Header().Set("Content-Disposition", contentDisposition(dispType, dr.FileName))
+	contentType := dr.ContentType
+	if len(contentType) == 0 {
+		contentType = mime.TypeByExtension(path.Ext(dr.FileName))
+	}
+	if len(contentType) == 0 {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(w, r, dr.FileName, dr.ModTime, dr.Content)
+}
+
+// DownloadResult create the result that sends the content as the downloaded file 'name'
+func (ctx *HttpCtx) DownloadResult(name string, content io.ReadSeeker, modTime time.Time) *DownloadResult {
+	assert.NotNil("content", content)
+	return &DownloadResult{FileName: name, Content: content, ModTime: modTime}
+}
+
+// zipEntry the file or the reader in the zip archive
+type zipEntry struct {
+	name     string
+	filePath string
+	reader   io.Reader
+	modTime  time.Time
+}
+
+// ZipResult the result that streams the files and the readers as the zip archive. The archive is written to
+// the response directly, so the size of the archive is not limited by the memory
+type ZipResult struct {
+	// FileName the file name of the archive that is saved by the client
+	FileName string
+	entries  []*zipEntry
+}
+
+// zipName clear the name of the entry in the archive, the name cannot be absolute or out of the archive
+func zipName(name string) string {
+	name = path.Clean("/" + strings.Replace(name, "\\", "/", -1))
+	return strings.TrimLeft(name, "/")
+}
+
+// AddFile add the file or the directory on the disk to the archive as 'name'. The base name of the file is used
+// if the name is empty or it's the root of the archive, for example "."
+func (zr *ZipResult) AddFile(name, filePath string) *ZipResult {
+	assert.NotEmpty("filePath", filePath)
+	entryName := zipName(name)
+	if len(entryName) == 0 {
+		entryName = zipName(filepath.Base(filePath))
+	}
+	zr.entries = append(zr.entries, &zipEntry{name: entryName, filePath: filePath})
+	return zr
+}
+
+// AddReader add the content of the reader to the archive as 'name'. The reader is closed if it's an io.Closer
+func (zr *ZipResult) AddReader(name string, reader io.Reader, modTime time.Time) *ZipResult {
+	assert.NotNil("reader", reader)
+	entryName := zipName(name)
+	assert.NotEmpty("name", entryName)
+	zr.entries = append(zr.entries, &zipEntry{name: entryName, reader: reader, modTime: modTime})
+	return zr
+}
+
+// closeReaders close the readers of the entries that are io.Closer
+func (zr *ZipResult) closeReaders() {
+	for _, entry := range zr.entries {
+		if closer, ok := entry.reader.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+}
+
+// writeReader write the content of the reader as the file in the archive
+func writeReader(zw *zip.Writer, name string, reader io.Reader, modTime time.Time) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, reader)
+	return err
+}
+
+// writeFile write the file or the directory on the disk to the archive
+func writeFile(zw *zip.Writer, name, filePath string) error {
+	return filepath.Walk(filePath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(filePath, p)
+		if err != nil {
+			return err
+		}
+		entryName := zipName(path.Join(name, filepath.ToSlash(rel)))
+		if info.IsDir() {
+			// the root of the archive has no directory entry
+			if len(entryName) == 0 {
+				return nil
+			}
+			_, err = zw.CreateHeader(&zip.FileHeader{Name: entryName + "/", Modified: info.ModTime()})
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return writeReader(zw, entryName, f, info.ModTime())
+	})
+}
+
+// ExecResult stream the zip archive. The files on the disk are checked before the response is started, the
+// status 404 is sent if any file does not exist. If any entry cannot be read after the response is started, the
+// client gets the status 200 with the invalid archive, and the error is only logged
+func (zr *ZipResult) ExecResult(w http.ResponseWriter, r *http.Request) {
+	defer zr.closeReaders()
+	for _, entry := range zr.entries {
+		if entry.reader != nil {
+			continue
+		}
+		if _, err := os.Stat(entry.filePath); err != nil {
+			code := http.StatusInternalServerError
+			if os.IsNotExist(err) {
+				code = http.StatusNotFound
+			}
+			log.Printf("mego: failed to write the zip archive %s: %v", zr.FileName, err)
+			http.Error(w, http.StatusText(code), code)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", zr.FileName))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == "HEAD" {
+		return
+	}
+	zw := zip.NewWriter(w)
+	for _, entry := range zr.entries {
+		var err error
+		if entry.reader != nil {
+			err = writeReader(zw, entry.name, entry.reader, entry.modTime)
+		} else {
+			err = writeFile(zw, entry.name, entry.filePath)
+		}
+		if err != nil {
+			log.Printf("mego: failed to write the zip archive %s: %v", zr.FileName, err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("mego: failed to write the zip archive %s: %v", zr.FileName, err)
+	}
+}
+
+// ZipResult create the result that streams the zip archive 'name'. The entries are added by AddFile and AddReader
+func (ctx *HttpCtx) ZipResult(name string) *ZipResult {
+	return &ZipResult{FileName: name}
+}
@@ -0,0 +1,59 @@
+package mego
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestZipResultMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	zr := &ZipResult{FileName: "files.zip"}
+	zr.AddFile("", filepath.Join(dir, "a.txt")).AddFile("", filepath.Join(dir, "missing.txt"))
+	rec := httptest.NewRecorder()
+	zr.ExecResult(rec, httptest.NewRequest("GET", "/files.zip", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct == "application/zip" {
+		t.Fatalf("unexpected content type %s", ct)
+	}
+
+	zr = &ZipResult{FileName: "files.zip"}
+	zr.AddFile("docs", dir).AddReader("b.txt", bytes.NewReader([]byte("b")), time.Now())
+	rec = httptest.NewRecorder()
+	zr.ExecResult(rec, httptest.NewRequest("GET", "/files.zip", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	body := rec.Body.Bytes()
+	zrd, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, f := range zrd.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+	if len(files) != 2 || files["docs/a.txt"] != "a" || files["b.txt"] != "b" {
+		t.Fatalf("unexpected files %v", files)
+	}
+}
@@ -26,7 +26,18 @@ func handle403(w http.ResponseWriter, r *http.Request) {
 	buf.WriteString("<h3>Error 403:  Forbidden</h3>")
 	buf.WriteString("<p>Access to this resource on the server is denied: <i>" + r.URL.String() + "</i></p>")
 	w.Header().Add("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(404)
+	w.WriteHeader(403)
+	w.Write(buf.Bytes())
+}
+
+// handle413 the default error 413 handler
+func handle413(w http.ResponseWriter, r *http.Request) {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("<h3>Error 413: Request Entity Too Large</h3>")
+	buf.WriteString("<p>The request sent by the client is larger than the server is willing to process: <i>" + r.URL.String() + "</i></p>")
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Connection", "close")
+	w.WriteHeader(413)
 	w.Write(buf.Bytes())
 }
 
@@ -36,7 +47,7 @@ func handle400(w http.ResponseWriter, r *http.Request) {
 	buf.WriteString("<h3>Error 400: Bad Request</h3>")
 	buf.WriteString("<p>The request sent by the client was syntactically incorrect: <i>" + r.URL.String() + "</i></p>")
 	w.Header().Add("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(404)
+	w.WriteHeader(400)
 	w.Write(buf.Bytes())
 }
 
@@ -0,0 +1,86 @@
+package mego
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// etagOf compute the strong entity tag of the response content
+func etagOf(content []byte) string {
+	sum := sha1.Sum(content)
+	return strAdd("\"", base64.RawURLEncoding.EncodeToString(sum[:]), "\"")
+}
+
+// quoteETag ensure the entity tag is a quoted string (or a weak tag 'W/"..."')
+func quoteETag(etag string) string {
+	if strings.HasPrefix(etag, "W/\"") || strings.HasPrefix(etag, "\"") {
+		return etag
+	}
+	return strAdd("\"", etag, "\"")
+}
+
+// etagMatch check if the entity tag matches one of the tags in the If-None-Match header.
+// the comparison is the weak comparison described in RFC 7232 section 2.3.2
+func etagMatch(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotModified evaluate the conditional request headers 'If-None-Match' and 'If-Modified-Since'.
+// it returns true if the client's copy of the resource is fresh
+func isNotModified(r *http.Request, etag string, modTime time.Time) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); len(inm) > 0 {
+		return len(etag) > 0 && etagMatch(inm, etag)
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if len(ims) == 0 || modTime.IsZero() || modTime.Unix() <= 0 {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	return !modTime.Truncate(time.Second).After(t)
+}
+
+// writeNotModified write the status code 304 to the response writer
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// checkNotModified add the ETag header to the response (if it is not set yet) and then evaluate
+// the conditional request. it returns true if the status code 304 has been written
+func checkNotModified(w http.ResponseWriter, r *http.Request, content []byte) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	etag := w.Header().Get("ETag")
+	if len(etag) == 0 {
+		etag = etagOf(content)
+		w.Header().Set("ETag", etag)
+	}
+	var modTime time.Time
+	if lm := w.Header().Get("Last-Modified"); len(lm) > 0 {
+		modTime, _ = http.ParseTime(lm)
+	}
+	if isNotModified(r, etag, modTime) {
+		writeNotModified(w)
+		return true
+	}
+	return false
+}
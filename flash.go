@@ -0,0 +1,170 @@
+package mego
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/simbory/mego/assert"
+)
+
+// Flash the one-time message that is carried to the next request, for example across the redirect
+type Flash struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// FlashStore the flash message store interface
+type FlashStore interface {
+	// Load get the pending flash messages of the client
+	Load(ctx *HttpCtx) []*Flash
+	// Save replace the pending flash messages of the client. The messages should be removed if flashes is empty
+	Save(ctx *HttpCtx, flashes []*Flash)
+}
+
+// cookieFlashStore the flash store that keeps the messages in a signed cookie
+type cookieFlashStore struct {
+	cookieName string
+}
+
+// Load get the flash messages from the signed cookie
+func (cs *cookieFlashStore) Load(ctx *HttpCtx) []*Flash {
+	value, ok := ctx.SignedCookie(cs.cookieName)
+	if !ok {
+		return nil
+	}
+	var flashes []*Flash
+	if json.Unmarshal(str2Byte(value), &flashes) != nil {
+		return nil
+	}
+	return flashes
+}
+
+// Save write the flash messages to the signed cookie, or delete the cookie if there is no message
+func (cs *cookieFlashStore) Save(ctx *HttpCtx, flashes []*Flash) {
+	removeSetCookie(ctx.res.Header(), cs.cookieName)
+	if len(flashes) == 0 {
+		if ctx.Cookie(cs.cookieName) != nil {
+			ctx.DeleteCookie(cs.cookieName, "/")
+		}
+		return
+	}
+	data, err := json.Marshal(flashes)
+	assert.PanicErr(err)
+	ctx.SetSignedCookie(&http.Cookie{
+		Name:     cs.cookieName,
+		Value:    byte2Str(data),
+		HttpOnly: true,
+	})
+}
+
+// removeSetCookie remove the 'Set-Cookie' headers of the cookie name that are added in the current request
+func removeSetCookie(h http.Header, name string) {
+	values := h["Set-Cookie"]
+	if len(values) == 0 {
+		return
+	}
+	kept := values[:0]
+	for _, v := range values {
+		if !strings.HasPrefix(v, name+"=") {
+			kept = append(kept, v)
+		}
+	}
+	if len(kept) == 0 {
+		h.Del("Set-Cookie")
+	} else {
+		h["Set-Cookie"] = kept
+	}
+}
+
+// flashState the flash messages of the context
+type flashState struct {
+	loaded  bool
+	current []*Flash
+	next    []*Flash
+}
+
+// defaultFlashStore the flash store of the servers without the flash store, it's set by UseDefaultFlashStore
+var defaultFlashStore FlashStore
+
+// UseDefaultFlashStore set the flash message store of all the servers that do not call Server.UseFlashStore.
+// It's called by session.UseAsDefault, so the messages are stored in the default session manager
+func UseDefaultFlashStore(store FlashStore) {
+	assert.NotNil("store", store)
+	defaultFlashStore = store
+}
+
+func (ctx *HttpCtx) flashStore() FlashStore {
+	if ctx.Server.flashStore != nil {
+		return ctx.Server.flashStore
+	}
+	if defaultFlashStore != nil {
+		return defaultFlashStore
+	}
+	return &cookieFlashStore{cookieName: "MEGO_FLASH"}
+}
+
+func (ctx *HttpCtx) loadFlashes() {
+	if ctx.flash.loaded {
+		return
+	}
+	ctx.flash.loaded = true
+	ctx.flash.current = ctx.flashStore().Load(ctx)
+}
+
+func (ctx *HttpCtx) saveFlashes() {
+	var flashes []*Flash
+	flashes = append(flashes, ctx.flash.current...)
+	flashes = append(flashes, ctx.flash.next...)
+	ctx.flashStore().Save(ctx, flashes)
+}
+
+// Flash add the one-time message that is available in the next request by Flashes or the view function 'flashes'.
+// The messages are stored in the FlashStore set by Server.UseFlashStore, or in the default session manager
+// (see session.UseAsDefault), or in a signed cookie if there is no session manager
+func (ctx *HttpCtx) Flash(kind, message string) {
+	ctx.loadFlashes()
+	ctx.flash.next = append(ctx.flash.next, &Flash{Kind: kind, Message: message})
+	ctx.saveFlashes()
+}
+
+// Flashes get the flash messages added by the previous request. The messages are removed once they are read, or
+// after the request if they are not read
+func (ctx *HttpCtx) Flashes() []*Flash {
+	ctx.loadFlashes()
+	flashes := ctx.flash.current
+	if len(flashes) > 0 {
+		ctx.flash.current = nil
+		ctx.saveFlashes()
+	}
+	return flashes
+}
+
+// dropFlashes remove the flash messages of the previous request that are not read by this request, so they are not
+// shown on an unrelated later page. It's executed before the response of the dynamic request is written
+func (ctx *HttpCtx) dropFlashes() {
+	ctx.loadFlashes()
+	if len(ctx.flash.current) > 0 {
+		ctx.flash.current = nil
+		ctx.saveFlashes()
+	}
+}
+
+// hasFlashes check if there are flash messages to read
+func (ctx *HttpCtx) hasFlashes() bool {
+	ctx.loadFlashes()
+	return len(ctx.flash.current) > 0
+}
+
+// noFlashes the default view function 'flashes' for the requests without flash messages
+func noFlashes() []*Flash {
+	return nil
+}
+
+// UseFlashStore set the flash message store of the server. The messages are stored in the default session manager
+// by default, or in a signed cookie if there is no session manager
+func (s *Server) UseFlashStore(store FlashStore) {
+	s.assertUnlocked()
+	assert.NotNil("store", store)
+	s.flashStore = store
+}
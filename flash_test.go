@@ -0,0 +1,55 @@
+package mego
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFlashDropped(t *testing.T) {
+	s := newTestServer(t, func(s *Server) {
+		s.Route("/set", func(ctx *HttpCtx) interface{} {
+			ctx.Flash("info", "saved")
+			return ctx.TextResult("set", "text/plain")
+		})
+		s.Route("/other", func(ctx *HttpCtx) interface{} {
+			return ctx.TextResult("other", "text/plain")
+		})
+		s.Route("/read", func(ctx *HttpCtx) interface{} {
+			var messages []string
+			for _, f := range ctx.Flashes() {
+				messages = append(messages, f.Message)
+			}
+			return ctx.TextResult(strings.Join(messages, ","), "text/plain")
+		})
+	})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	get := func(p string) string {
+		res, err := client.Get(ts.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return string(body)
+	}
+	get("/set")
+	if body := get("/read"); body != "saved" {
+		t.Fatalf("the flash message is not read by the next request: %q", body)
+	}
+	if body := get("/read"); body != "" {
+		t.Fatalf("the flash message is read twice: %q", body)
+	}
+	// the unread message is dropped after the next request
+	get("/set")
+	get("/other")
+	if body := get("/read"); body != "" {
+		t.Fatalf("the unread flash message is kept: %q", body)
+	}
+}
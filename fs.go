@@ -0,0 +1,24 @@
+package mego
+
+import (
+	"io/fs"
+	"strings"
+
+	"github.com/simbory/mego/assert"
+)
+
+// UseFS set the file system of the web root, for example the embed.FS. The content files are served from the directory
+// 'www' of the file system, and the views are read from the directories 'views' and '<area>/views' of the file system.
+// The files in the file system are not watched, so it's recommended to use the web root directory in development
+func (s *Server) UseFS(fsys fs.FS) {
+	s.assertUnlocked()
+	assert.NotNil("fsys", fsys)
+	s.webFS = fsys
+}
+
+// subFS get the sub directory of the web root file system
+func (s *Server) subFS(dir string) fs.FS {
+	sub, err := fs.Sub(s.webFS, strings.Trim(ClearPath(dir), "/"))
+	assert.PanicErr(err)
+	return sub
+}
@@ -10,24 +10,30 @@ func (fk hijackKey) match(urlPath string) bool {
 	return string(fk) == urlPath || strings.HasPrefix(urlPath, string(fk)+"/")
 }
 
-// hijackContainer the mego hijack container
-type hijackContainer map[hijackKey]func(*HttpCtx)
+// hijackRule the mego hijack rule
+type hijackRule struct {
+	key hijackKey
+	h   func(*HttpCtx)
+}
+
+// hijackContainer the mego hijack container. the hijack rules are executed in the order they are added
+type hijackContainer []*hijackRule
 
 // exec hijack the request
 func (fc hijackContainer) exec(urlPath string, ctx *HttpCtx) {
-	for key, f := range fc {
-		if !key.match(urlPath) {
+	for _, rule := range fc {
+		if !rule.key.match(urlPath) {
 			continue
 		}
-		if f(ctx); ctx.ended {
+		if rule.h(ctx); ctx.ended {
 			break
 		}
 	}
 }
 
 // add add a new hijack rule
-func (fc hijackContainer) add(pathPrefix string, f func(*HttpCtx)) {
+func (fc *hijackContainer) add(pathPrefix string, f func(*HttpCtx)) {
 	pathPrefix = EnsurePrefix(pathPrefix, "/")
 	pathPrefix = strings.TrimRight(pathPrefix, "/")
-	fc[hijackKey(pathPrefix)] = f
-}
\ No newline at end of file
+	*fc = append(*fc, &hijackRule{key: hijackKey(pathPrefix), h: f})
+}
@@ -4,16 +4,14 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"html/template"
 	"github.com/simbory/mego/assert"
 	"net/http"
 	"regexp"
 	"net/url"
+	"time"
 )
 
-type sizer interface {
-	Size() int64
-}
-
 // HttpCtx the mego http context struct
 type HttpCtx struct {
 	req         *http.Request
@@ -24,6 +22,14 @@ type HttpCtx struct {
 	area        *Area
 	ctxId       uint64
 	queryValues *url.Values
+	viewFuncs   template.FuncMap
+	endHandlers []func()
+	user        Principal
+	flash       flashState
+	uploads     map[string][]*UploadFile
+	uploadErr   error
+	writeHooks  *hookWriter
+	outputEntry *outputCacheEntry
 
 	Server *Server
 }
@@ -43,6 +49,24 @@ func (ctx *HttpCtx) Response() http.ResponseWriter {
 	return ctx.res
 }
 
+// ClientIP get the client IP address. The 'Forwarded' and 'X-Forwarded-For' headers are only honored
+// if the request is sent by a trusted proxy set by Server.TrustProxies
+func (ctx *HttpCtx) ClientIP() string {
+	return ctx.Server.clientIP(ctx.req)
+}
+
+// Scheme get the scheme ('http' or 'https') that the client used to send the request. The 'Forwarded' and
+// 'X-Forwarded-Proto' headers are only honored if the request is sent by a trusted proxy
+func (ctx *HttpCtx) Scheme() string {
+	return ctx.Server.scheme(ctx.req)
+}
+
+// Host get the host that the client requested. The 'Forwarded' and 'X-Forwarded-Host' headers are only honored
+// if the request is sent by a trusted proxy
+func (ctx *HttpCtx) Host() string {
+	return ctx.Server.host(ctx.req)
+}
+
 // QueryStr get the value from the url query string
 func (ctx *HttpCtx) QueryStr(key string) string {
 	if ctx.queryValues == nil {
@@ -52,11 +76,24 @@ func (ctx *HttpCtx) QueryStr(key string) string {
 	return ctx.queryValues.Get(key)
 }
 
-// FormValue get the form value from request. It's the same as ctx.Request().FormValue(key)
+// FormValue get the form value from request. It's the same as ctx.Request().FormValue(key), but the multipart
+// form is parsed by PostFiles, so the size limits of the upload option are applied
 func (ctx *HttpCtx) FormValue(key string) string {
+	if isMultipart(ctx.req) {
+		ctx.parseFiles()
+	}
 	return ctx.req.FormValue(key)
 }
 
+// PostFormValue get the form value from the request body. It's the same as ctx.Request().PostFormValue(key), but
+// the multipart form is parsed by PostFiles, so the size limits of the upload option are applied
+func (ctx *HttpCtx) PostFormValue(key string) string {
+	if isMultipart(ctx.req) {
+		ctx.parseFiles()
+	}
+	return ctx.req.PostFormValue(key)
+}
+
 // RouteString get the route parameter value as string by key
 func (ctx *HttpCtx) RouteVar(key string) string {
 	if ctx.routeData == nil {
@@ -65,22 +102,17 @@ func (ctx *HttpCtx) RouteVar(key string) string {
 	return ctx.routeData[key]
 }
 
-// PostFile get the post file info
+// PostFile get the first posted file of the file input. The file has the error http.ErrMissingFile if there is
+// no such file
 func (ctx *HttpCtx) PostFile(formName string) *UploadFile {
-	f, h, err := ctx.Request().FormFile(formName)
+	files, err := ctx.PostFiles(formName)
 	if err != nil {
 		return &UploadFile{Error: err}
 	}
-	if f == nil {
-		return nil
-	}
-	var size int64
-	if s, ok := f.(sizer); ok {
-		size = s.Size()
-	} else {
-		size = 0
+	if len(files) == 0 {
+		return &UploadFile{Error: http.ErrMissingFile}
 	}
-	return &UploadFile{FileName: h.Filename, Size: size, File: f, Header: h}
+	return files[0]
 }
 
 // SetCtxItem add context data to mego context
@@ -167,17 +199,53 @@ func (ctx *HttpCtx) FileResult(path string, contentType string) Result {
 	return resp
 }
 
-// ViewResult find the view by view name, execute the view template and get the result
+// ViewResult find the view by view name, execute the view template and get the result.
+// The flash messages are available in the view by the view function 'flashes'
 func (ctx *HttpCtx) ViewResult(viewName string, data interface{}) Result {
+	if ctx.hasFlashes() {
+		ctx.ExtendView("flashes", ctx.Flashes)
+	}
 	if ctx.area != nil {
 		ctx.area.initViewEngine()
-		return ctx.area.viewEngine.Render(viewName, data)
+		return ctx.area.viewEngine.render(viewName, data, ctx.viewFuncs)
 	} else {
 		ctx.Server.initViewEngine()
-		return ctx.Server.viewEngine.Render(viewName, data)
+		return ctx.Server.viewEngine.render(viewName, data, ctx.viewFuncs)
+	}
+}
+
+// ExtendView override the view function 'name' for the views rendered in the current context only.
+// The function must be added to the view engine by Server.ExtendView or Area.ExtendView first
+func (ctx *HttpCtx) ExtendView(name string, f interface{}) {
+	if len(name) == 0 || f == nil {
+		return
+	}
+	if ctx.viewFuncs == nil {
+		ctx.viewFuncs = make(template.FuncMap)
+	}
+	ctx.viewFuncs[name] = f
+}
+
+// SetVersion declare the version (entity tag) and the last modification time of the requested resource.
+// if the client's copy of the resource is fresh, the status code 304 is sent and the context is ended, so
+// that the expensive work of the handler can be skipped. pass an empty version or a zero time to ignore it.
+func (ctx *HttpCtx) SetVersion(version string, modTime time.Time) {
+	var etag string
+	if len(version) > 0 {
+		etag = quoteETag(version)
+		ctx.res.Header().Set("ETag", etag)
+	}
+	if !modTime.IsZero() {
+		ctx.res.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+	if isNotModified(ctx.req, etag, modTime) {
+		writeNotModified(ctx.res)
+		ctx.End()
 	}
 }
 
+// Redirect redirect the request to urlStr and end the context. if the value of 'permanent' is true,
+// the status code is 301, else the status code is 302
 func (ctx *HttpCtx) Redirect(urlStr string, permanent bool) {
 	if permanent {
 		http.Redirect(ctx.res, ctx.req, urlStr, 301)
@@ -203,6 +271,85 @@ func (ctx *HttpCtx) RedirectResult(urlStr string, permanent bool) Result {
 	}
 }
 
+// Forbidden write the status code 403 by the error handler set by Server.Handle403 and end the context
+func (ctx *HttpCtx) Forbidden() {
+	ctx.Server.err403Handler(ctx.res, ctx.req)
+	ctx.End()
+}
+
+// OnEnd register the handler that is executed after the response of the current request is written.
+// The handlers are executed in reverse order, even if the context is ended or the request is panicked
+func (ctx *HttpCtx) OnEnd(h func()) {
+	if h != nil {
+		ctx.endHandlers = append(ctx.endHandlers, h)
+	}
+}
+
+func (ctx *HttpCtx) execEndHandlers() {
+	for i := len(ctx.endHandlers) - 1; i >= 0; i-- {
+		ctx.endHandlers[i]()
+	}
+	ctx.endHandlers = nil
+}
+
+// hookWriter the response writer that executes the handlers before the response headers are written
+type hookWriter struct {
+	http.ResponseWriter
+	handlers []func()
+	written  bool
+}
+
+func (hw *hookWriter) before() {
+	if hw.written {
+		return
+	}
+	hw.written = true
+	// the handlers may register the other handlers, for example the session cookie is saved after the flash
+	// messages are dropped
+	for i := 0; i < len(hw.handlers); i++ {
+		hw.handlers[i]()
+	}
+}
+
+// WriteHeader execute the handlers and then write the status code
+func (hw *hookWriter) WriteHeader(code int) {
+	hw.before()
+	hw.ResponseWriter.WriteHeader(code)
+}
+
+// Write execute the handlers and then write the response body
+func (hw *hookWriter) Write(p []byte) (int, error) {
+	hw.before()
+	return hw.ResponseWriter.Write(p)
+}
+
+// Flush flush the response if the underlying response writer supports it
+func (hw *hookWriter) Flush() {
+	hw.before()
+	if f, ok := hw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap get the underlying response writer for http.ResponseController
+func (hw *hookWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
+
+// OnBeforeWrite register the handler that is executed once before the response headers are written, so the
+// handler can still add the headers and the cookies, for example to save the session data in the cookie.
+// The handlers are executed in order. The response writer should be got by Response after the handler is registered
+func (ctx *HttpCtx) OnBeforeWrite(h func()) {
+	if h == nil {
+		return
+	}
+	if ctx.writeHooks == nil {
+		ctx.writeHooks = &hookWriter{ResponseWriter: ctx.res}
+		ctx.res = ctx.writeHooks
+	}
+	ctx.writeHooks.handlers = append(ctx.writeHooks.handlers, h)
+}
+
 // End end the mego context and stop the rest request function
 func (ctx *HttpCtx) End() {
 	ctx.ended = true
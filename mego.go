@@ -46,7 +46,7 @@ func (s *Server) GetArea(pathPrefix string) *Area {
 	return &Area{
 		pathPrefix: prefix,
 		server:     s,
-		hijackColl: make(hijackContainer),
+		hijackColl: make(hijackContainer, 0),
 	}
 }
 
@@ -90,6 +90,14 @@ func (s *Server) Handle403(h http.HandlerFunc) {
 	}
 }
 
+// Handle413 set custom error handler for status code 413
+func (s *Server) Handle413(h http.HandlerFunc) {
+	s.assertUnlocked()
+	if h != nil {
+		s.err413Handler = h
+	}
+}
+
 // Handle500 set custom error handler for status code 500
 func (s *Server) Handle500(h func(http.ResponseWriter, *http.Request, interface{})) {
 	s.assertUnlocked()
@@ -115,6 +123,21 @@ func (s *Server) ExtendView(name string, f interface{}) {
 	s.viewEngine.ExtendView(name, f)
 }
 
+// SetLayout set the default layout of the server views. The '_viewstart' files in the views override the default layout
+func (s *Server) SetLayout(layout string) {
+	s.assertUnlocked()
+	s.initViewEngine()
+	s.viewEngine.SetLayout(layout)
+}
+
+// SetViewLocations set the directories relative to the view directory where the views, the layouts and the partial views
+// are searched in order. The default locations are the view directory and its 'shared' directory
+func (s *Server) SetViewLocations(locations ...string) {
+	s.assertUnlocked()
+	s.initViewEngine()
+	s.viewEngine.SetLocations(locations...)
+}
+
 // Run run the application as http
 func (s *Server) Run() {
 	s.onInit()
@@ -215,7 +238,8 @@ func NewServer(webRoot, addr string) *Server {
 		err500Handler: handle500,
 		err400Handler: handle400,
 		err403Handler: handle403,
-		hijackColl:    make(hijackContainer),
+		err413Handler: handle413,
+		hijackColl:    make(hijackContainer, 0),
 		serverVar:     make(map[string]interface{}),
 	}
 	return s
@@ -0,0 +1,23 @@
+package mego
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestServer create the server in the temporary web root. The routes are added by setup before the server
+// is initialized
+func newTestServer(t *testing.T, setup func(s *Server)) *Server {
+	s := NewServer(t.TempDir(), ":0")
+	setup(s)
+	s.onInit()
+	return s
+}
+
+// serve send the request to the server and get the recorded response
+func serve(s *Server, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
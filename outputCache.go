@@ -0,0 +1,387 @@
+package mego
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simbory/mego/assert"
+	"github.com/simbory/mego/cache"
+)
+
+// OutputCacheOption the output cache option of the dynamic requests
+type OutputCacheOption struct {
+	// Name the name of the output cache rule, it can be used to purge the cached pages by PurgeOutputCache
+	Name string
+	// Duration the duration that the pages are cached, zero or negative value means never expire
+	Duration time.Duration
+	// VaryByQuery the query string keys that the cached pages vary by, "*" means all the query string keys
+	VaryByQuery []string
+	// VaryByHeader the request headers that the cached pages vary by
+	VaryByHeader []string
+	// VaryByCookie the cookie names that the cached pages vary by
+	VaryByCookie []string
+	// Tags the tags of the cached pages, it can be used to purge the cached pages by PurgeOutputCacheTag
+	Tags []string
+	// Dependencies the files that the cached pages depend on. The view files of the view results are added automatically
+	Dependencies []string
+	// Manager the cache manager that the pages are stored in. A private cache manager is used if it's nil
+	Manager *cache.Manager
+}
+
+// cachedOutput the cached response
+type cachedOutput struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+// write write the cached response to the response writer
+func (co *cachedOutput) write(w http.ResponseWriter, r *http.Request) {
+	for key, values := range co.header {
+		w.Header()[key] = append([]string(nil), values...)
+	}
+	var modTime time.Time
+	if lm := co.header.Get("Last-Modified"); len(lm) > 0 {
+		modTime, _ = http.ParseTime(lm)
+	}
+	if co.statusCode == 200 && isNotModified(r, co.header.Get("ETag"), modTime) {
+		writeNotModified(w)
+		return
+	}
+	w.WriteHeader(co.statusCode)
+	w.Write(co.body)
+}
+
+// outputRecorder the response writer that records the response of the request
+type outputRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	buf        bytes.Buffer
+	header     http.Header
+}
+
+// WriteHeader record the status code and the response headers
+func (rec *outputRecorder) WriteHeader(code int) {
+	if rec.statusCode == 0 {
+		rec.statusCode = code
+		rec.header = rec.Header().Clone()
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// Write record the response body
+func (rec *outputRecorder) Write(p []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.WriteHeader(200)
+	}
+	rec.buf.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// output get the recorded response. The responses that set the cookies or are marked as private are not cached
+func (rec *outputRecorder) output() *cachedOutput {
+	if rec.statusCode != 200 || rec.header == nil || len(rec.header["Set-Cookie"]) > 0 {
+		return nil
+	}
+	for _, value := range rec.header["Cache-Control"] {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.ToLower(strings.TrimSpace(directive))
+			if directive == "private" || directive == "no-store" || strings.HasPrefix(directive, "private=") {
+				return nil
+			}
+		}
+	}
+	return &cachedOutput{
+		statusCode: rec.statusCode,
+		header:     rec.header,
+		body:       rec.buf.Bytes(),
+	}
+}
+
+// outputCacheEntry the output cache state of the request whose response is being recorded
+type outputCacheEntry struct {
+	rule     *outputCacheRule
+	key      string
+	recorder *outputRecorder
+}
+
+var (
+	privateCookies    = make(map[string]bool)
+	privateCookieLock sync.RWMutex
+)
+
+// RegisterPrivateCookie register the cookie that identifies the user, for example the session cookie. The output
+// cache is bypassed for the requests that carry the cookie
+func RegisterPrivateCookie(name string) {
+	assert.NotEmpty("name", name)
+	privateCookieLock.Lock()
+	defer privateCookieLock.Unlock()
+	privateCookies[name] = true
+}
+
+// isPrivateRequest check if the response of the request belongs to the user: the user is authenticated, or the
+// request carries the credentials or the private cookies
+func isPrivateRequest(ctx *HttpCtx) bool {
+	if ctx.user != nil || len(ctx.req.Header.Get("Authorization")) > 0 {
+		return true
+	}
+	privateCookieLock.RLock()
+	defer privateCookieLock.RUnlock()
+	for _, c := range ctx.req.Cookies() {
+		if privateCookies[c.Name] {
+			return true
+		}
+	}
+	return false
+}
+
+// outputCacheRule the output cache rule
+type outputCacheRule struct {
+	prefix hijackKey
+	opt    *OutputCacheOption
+}
+
+// key generate the cache key of the request
+func (rule *outputCacheRule) key(r *http.Request, urlPath string) string {
+	buf := bytes.NewBufferString("mego_output:")
+	buf.WriteString(string(rule.prefix))
+	buf.WriteByte('|')
+	buf.WriteString(r.Method)
+	buf.WriteByte('|')
+	buf.WriteString(strings.ToLower(urlPath))
+	// the CORS headers of the response vary by the origin
+	buf.WriteString("|o:")
+	buf.WriteString(url.QueryEscape(r.Header.Get("Origin")))
+	if len(rule.opt.VaryByQuery) > 0 {
+		query := r.URL.Query()
+		keys := rule.opt.VaryByQuery
+		if len(keys) == 1 && keys[0] == "*" {
+			keys = make([]string, 0, len(query))
+			for key := range query {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+		}
+		for _, key := range keys {
+			buf.WriteString("|q:")
+			buf.WriteString(url.QueryEscape(key))
+			buf.WriteByte('=')
+			buf.WriteString(url.QueryEscape(strings.Join(query[key], ",")))
+		}
+	}
+	for _, key := range rule.opt.VaryByHeader {
+		buf.WriteString("|h:")
+		buf.WriteString(strings.ToLower(key))
+		buf.WriteByte('=')
+		buf.WriteString(url.QueryEscape(r.Header.Get(key)))
+	}
+	for _, name := range rule.opt.VaryByCookie {
+		buf.WriteString("|c:")
+		buf.WriteString(url.QueryEscape(name))
+		buf.WriteByte('=')
+		if c, err := r.Cookie(name); err == nil {
+			buf.WriteString(url.QueryEscape(c.Value))
+		}
+	}
+	return buf.String()
+}
+
+// varies check if the cache key covers all the request headers that the response varies by. The response that
+// varies by the other headers is not cached, because it would be served to the requests with the other values
+func (rule *outputCacheRule) varies(header http.Header) bool {
+	for _, value := range header["Vary"] {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if len(name) == 0 || name == "Origin" {
+				continue
+			}
+			found := false
+			for _, key := range rule.opt.VaryByHeader {
+				if http.CanonicalHeaderKey(key) == name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// outputCache the output cache container of the server
+type outputCache struct {
+	rules   []*outputCacheRule
+	manager *cache.Manager
+	names   map[string]map[string]*cache.Manager
+	tags    map[string]map[string]*cache.Manager
+	lock    sync.Mutex
+}
+
+// add add a new output cache rule
+func (oc *outputCache) add(pathPrefix string, opt *OutputCacheOption) {
+	pathPrefix = EnsurePrefix(pathPrefix, "/")
+	pathPrefix = strings.TrimRight(pathPrefix, "/")
+	oc.rules = append(oc.rules, &outputCacheRule{prefix: hijackKey(pathPrefix), opt: opt})
+}
+
+// match find the output cache rule with the longest path prefix that matches the urlPath
+func (oc *outputCache) match(urlPath string) *outputCacheRule {
+	var found *outputCacheRule
+	for _, rule := range oc.rules {
+		if rule.prefix.match(urlPath) {
+			if found == nil || len(rule.prefix) > len(found.prefix) {
+				found = rule
+			}
+		}
+	}
+	return found
+}
+
+// cacheManager get the cache manager of the rule
+func (oc *outputCache) cacheManager(rule *outputCacheRule) *cache.Manager {
+	if rule.opt.Manager != nil {
+		return rule.opt.Manager
+	}
+	oc.lock.Lock()
+	defer oc.lock.Unlock()
+	if oc.manager == nil {
+		oc.manager = cache.NewManager(cache.DefaultGCInterval)
+	}
+	return oc.manager
+}
+
+// get get the cached response
+func (oc *outputCache) get(rule *outputCacheRule, key string) *cachedOutput {
+	data := oc.cacheManager(rule).Get(key)
+	if data == nil {
+		return nil
+	}
+	output, _ := data.(*cachedOutput)
+	return output
+}
+
+// set store the response to the cache manager
+func (oc *outputCache) set(rule *outputCacheRule, key string, output *cachedOutput, files []string) {
+	mgr := oc.cacheManager(rule)
+	deps := append(append([]string(nil), rule.opt.Dependencies...), files...)
+	if err := mgr.Set(key, output, deps, rule.opt.Duration); err != nil {
+		return
+	}
+	oc.lock.Lock()
+	defer oc.lock.Unlock()
+	if len(rule.opt.Name) > 0 {
+		oc.names = indexCacheKey(oc.names, rule.opt.Name, key, mgr)
+	}
+	for _, tag := range rule.opt.Tags {
+		oc.tags = indexCacheKey(oc.tags, tag, key, mgr)
+	}
+}
+
+// purge remove the cached responses of the rule name (or the tag if byTag is true) from the cache managers
+func (oc *outputCache) purge(name string, byTag bool) {
+	oc.lock.Lock()
+	index := oc.names
+	if byTag {
+		index = oc.tags
+	}
+	keys := index[name]
+	delete(index, name)
+	oc.lock.Unlock()
+	for key, mgr := range keys {
+		mgr.Remove(key)
+	}
+}
+
+// indexCacheKey add the cache key to the index with the name
+func indexCacheKey(index map[string]map[string]*cache.Manager, name, key string, mgr *cache.Manager) map[string]map[string]*cache.Manager {
+	if index == nil {
+		index = make(map[string]map[string]*cache.Manager)
+	}
+	if index[name] == nil {
+		index[name] = make(map[string]*cache.Manager)
+	}
+	index[name][key] = mgr
+	return index
+}
+
+// serveOutputCache write the cached response of the request and return true. If the response is not cached, the
+// response writer of the context is wrapped to record the response
+func (s *Server) serveOutputCache(ctx *HttpCtx, urlPath string) bool {
+	r := ctx.req
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	rule := s.outputCache.match(urlPath)
+	if rule == nil || isPrivateRequest(ctx) {
+		return false
+	}
+	key := rule.key(r, urlPath)
+	if output := s.outputCache.get(rule, key); output != nil {
+		output.write(ctx.res, r)
+		return true
+	}
+	// the recorder is put under the write hooks, so the headers added by the hooks are recorded
+	recorder := &outputRecorder{}
+	if ctx.writeHooks != nil {
+		recorder.ResponseWriter = ctx.writeHooks.ResponseWriter
+		ctx.writeHooks.ResponseWriter = recorder
+	} else {
+		recorder.ResponseWriter = ctx.res
+		ctx.res = recorder
+	}
+	ctx.outputEntry = &outputCacheEntry{rule: rule, key: key, recorder: recorder}
+	return false
+}
+
+// storeOutputCache store the recorded response of the request
+func (s *Server) storeOutputCache(ctx *HttpCtx, result interface{}) {
+	entry := ctx.outputEntry
+	if entry == nil || isPrivateRequest(ctx) {
+		return
+	}
+	output := entry.recorder.output()
+	if output == nil || !entry.rule.varies(output.header) {
+		return
+	}
+	var files []string
+	if vr, ok := result.(*viewResult); ok {
+		if vf, ok := vr.engine.(interface{ ViewFiles(string) []string }); ok {
+			files = vf.ViewFiles(vr.viewName)
+		}
+	}
+	s.outputCache.set(entry.rule, entry.key, output, files)
+}
+
+// OutputCache cache the responses of the dynamic requests that start with pathPrefix
+func (s *Server) OutputCache(pathPrefix string, opt *OutputCacheOption) {
+	s.assertUnlocked()
+	assert.NotEmpty("pathPrefix", pathPrefix)
+	assert.NotNil("opt", opt)
+	s.outputCache.add(ClearPath(pathPrefix), opt)
+}
+
+// PurgeOutputCache remove the cached responses of the output cache rule with the name
+func (s *Server) PurgeOutputCache(name string) {
+	s.outputCache.purge(name, false)
+}
+
+// PurgeOutputCacheTag remove the cached responses with the tag
+func (s *Server) PurgeOutputCacheTag(tag string) {
+	s.outputCache.purge(tag, true)
+}
+
+// OutputCache cache the responses of the area dynamic requests that start with pathPrefix
+func (a *Area) OutputCache(pathPrefix string, opt *OutputCacheOption) {
+	a.server.assertUnlocked()
+	assert.NotEmpty("pathPrefix", pathPrefix)
+	assert.NotNil("opt", opt)
+	prefix := ClearPath(pathPrefix)
+	a.server.outputCache.add(a.pathPrefix+"/"+strings.Trim(prefix, "/"), opt)
+}
@@ -0,0 +1,51 @@
+package mego
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOutputCacheOrigin(t *testing.T) {
+	count := 0
+	s := newTestServer(t, func(s *Server) {
+		s.EnableCors(&CorsOption{AllowedOrigins: []string{"https://a.example", "https://b.example"}})
+		s.OutputCache("/", &OutputCacheOption{})
+		s.Route("/page", func(ctx *HttpCtx) interface{} {
+			count++
+			return ctx.TextResult("page", "text/plain")
+		})
+	})
+	for i, origin := range []string{"https://a.example", "https://b.example", "https://a.example"} {
+		r := httptest.NewRequest("GET", "/page", nil)
+		r.Header.Set("Origin", origin)
+		w := serve(s, r)
+		if acao := w.Header().Get("Access-Control-Allow-Origin"); acao != origin {
+			t.Fatalf("request %d: unexpected Access-Control-Allow-Origin %q for %q", i, acao, origin)
+		}
+	}
+	if count != 2 {
+		t.Fatalf("the responses are not cached by the origin: %d", count)
+	}
+}
+
+func TestOutputCacheVary(t *testing.T) {
+	count := 0
+	s := newTestServer(t, func(s *Server) {
+		s.OutputCache("/", &OutputCacheOption{})
+		s.Route("/page", func(ctx *HttpCtx) interface{} {
+			count++
+			ctx.Response().Header().Set("Vary", "Accept-Language")
+			return ctx.TextResult(ctx.Request().Header.Get("Accept-Language"), "text/plain")
+		})
+	})
+	for _, lang := range []string{"en", "fr"} {
+		r := httptest.NewRequest("GET", "/page", nil)
+		r.Header.Set("Accept-Language", lang)
+		if body := serve(s, r).Body.String(); body != lang {
+			t.Fatalf("unexpected body %q for %q", body, lang)
+		}
+	}
+	if count != 2 {
+		t.Fatalf("the response that varies by the request header is cached")
+	}
+}
@@ -0,0 +1,19 @@
+package mego
+
+// Principal the authenticated user of the request
+type Principal interface {
+	// Name get the name of the user
+	Name() string
+	// IsInRole check if the user is in the role
+	IsInRole(role string) bool
+}
+
+// User get the authenticated user of the current request. It returns nil if the request is not authenticated
+func (ctx *HttpCtx) User() Principal {
+	return ctx.user
+}
+
+// SetUser set the authenticated user of the current request
+func (ctx *HttpCtx) SetUser(user Principal) {
+	ctx.user = user
+}
@@ -0,0 +1,185 @@
+package mego
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/simbory/mego/assert"
+)
+
+// forwardedElem the forwarded information of one proxy hop
+type forwardedElem struct {
+	forIP string
+	proto string
+	host  string
+}
+
+// parseForwarded parse the 'Forwarded' header (RFC 7239), or the 'X-Forwarded-*' headers if the 'Forwarded' header is absent.
+// the elements are in the order that the proxies add them, the first element is the client
+func parseForwarded(r *http.Request) []*forwardedElem {
+	var elems []*forwardedElem
+	if values := r.Header.Values("Forwarded"); len(values) > 0 {
+		for _, value := range values {
+			for _, part := range strings.Split(value, ",") {
+				elem := &forwardedElem{}
+				for _, pair := range strings.Split(part, ";") {
+					kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+					if len(kv) != 2 {
+						continue
+					}
+					v := strings.Trim(strings.TrimSpace(kv[1]), "\"")
+					switch strings.ToLower(kv[0]) {
+					case "for":
+						elem.forIP = trimIPPort(v)
+					case "proto":
+						elem.proto = strings.ToLower(v)
+					case "host":
+						elem.host = v
+					}
+				}
+				elems = append(elems, elem)
+			}
+		}
+		return elems
+	}
+	for _, ip := range headerValues(r, "X-Forwarded-For") {
+		elems = append(elems, &forwardedElem{forIP: trimIPPort(ip)})
+	}
+	protos := headerValues(r, "X-Forwarded-Proto")
+	hosts := headerValues(r, "X-Forwarded-Host")
+	if len(elems) == 0 && (len(protos) > 0 || len(hosts) > 0) {
+		elems = append(elems, &forwardedElem{})
+	}
+	// every proxy appends the values of its hop to the headers, so the values are aligned with the hops from the
+	// nearest proxy. the values of the hops that are missing in the headers are left empty
+	for i, proto := range protos {
+		if j := len(elems) - len(protos) + i; j >= 0 {
+			elems[j].proto = strings.ToLower(proto)
+		}
+	}
+	for i, host := range hosts {
+		if j := len(elems) - len(hosts) + i; j >= 0 {
+			elems[j].host = host
+		}
+	}
+	return elems
+}
+
+// headerValues get the comma separated values of all the headers with the name
+func headerValues(r *http.Request, name string) []string {
+	var values []string
+	for _, value := range r.Header.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			values = append(values, strings.TrimSpace(v))
+		}
+	}
+	return values
+}
+
+// trimIPPort remove the port and the brackets of the IPv6 address
+func trimIPPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
+
+// isTrustedProxy check if the IP address is a trusted proxy
+func (s *Server) isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range s.trustedProxies {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwarded get the forwarded elements of the request if the request is sent by a trusted proxy.
+// the index of the element added by the proxy that the client connected to is returned as well
+func (s *Server) forwarded(r *http.Request) ([]*forwardedElem, int) {
+	if len(s.trustedProxies) == 0 || !s.isTrustedProxy(trimIPPort(r.RemoteAddr)) {
+		return nil, -1
+	}
+	elems := parseForwarded(r)
+	if len(elems) == 0 {
+		return nil, -1
+	}
+	// walk from the nearest proxy back to the client, the first untrusted address is the client
+	for i := len(elems) - 1; i >= 0; i-- {
+		if !s.isTrustedProxy(elems[i].forIP) {
+			return elems, i
+		}
+	}
+	return elems, 0
+}
+
+// clientHop get the forwarded element of the client, so the client IP address, the scheme and the host are
+// resolved from the same hop. The proto and the host that are missing in the element of the client are taken from
+// the nearest element after it, which is added by a trusted proxy, for example if only the edge proxy sets
+// 'X-Forwarded-Proto'. It returns nil if the request is not forwarded by a trusted proxy
+func (s *Server) clientHop(r *http.Request) *forwardedElem {
+	elems, i := s.forwarded(r)
+	if i < 0 {
+		return nil
+	}
+	hop := *elems[i]
+	for j := i + 1; j < len(elems); j++ {
+		if len(hop.proto) == 0 {
+			hop.proto = elems[j].proto
+		}
+		if len(hop.host) == 0 {
+			hop.host = elems[j].host
+		}
+	}
+	return &hop
+}
+
+// clientIP get the client IP address of the request
+func (s *Server) clientIP(r *http.Request) string {
+	if hop := s.clientHop(r); hop != nil && len(hop.forIP) > 0 {
+		return hop.forIP
+	}
+	return trimIPPort(r.RemoteAddr)
+}
+
+// scheme get the scheme ('http' or 'https') that the client used to send the request
+func (s *Server) scheme(r *http.Request) string {
+	if hop := s.clientHop(r); hop != nil && len(hop.proto) > 0 {
+		return hop.proto
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// host get the host that the client requested
+func (s *Server) host(r *http.Request) string {
+	if hop := s.clientHop(r); hop != nil && len(hop.host) > 0 {
+		return hop.host
+	}
+	return r.Host
+}
+
+// TrustProxies set the trusted proxies by CIDRs (like '10.0.0.0/8') or IP addresses. The 'Forwarded' and
+// 'X-Forwarded-*' headers are only honored if the request is sent by a trusted proxy.
+func (s *Server) TrustProxies(cidrs ...string) {
+	s.assertUnlocked()
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr = cidr + "/32"
+			} else {
+				cidr = cidr + "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		assert.PanicErr(err)
+		s.trustedProxies = append(s.trustedProxies, n)
+	}
+}
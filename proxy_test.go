@@ -0,0 +1,44 @@
+package mego
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwardedHop(t *testing.T) {
+	s := NewServer(t.TempDir(), ":0")
+	s.TrustProxies("10.0.0.0/8")
+	// only the edge proxy 10.0.0.1 sets the scheme and the host, the inner proxy 10.0.0.2 appends the address
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.2:1234"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	r.Header.Set("X-Forwarded-Host", "example.com")
+	if ip, scheme, host := s.clientIP(r), s.scheme(r), s.host(r); ip != "203.0.113.7" || scheme != "https" || host != "example.com" {
+		t.Fatalf("unexpected client %s %s %s", ip, scheme, host)
+	}
+	// the values of the untrusted hops are ignored
+	r.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.7")
+	r.Header.Set("X-Forwarded-Proto", "gopher, https")
+	if ip, scheme := s.clientIP(r), s.scheme(r); ip != "203.0.113.7" || scheme != "https" {
+		t.Fatalf("unexpected client %s %s", ip, scheme)
+	}
+	// the request of the untrusted client is not forwarded
+	r.RemoteAddr = "198.51.100.2:1234"
+	if ip, scheme, host := s.clientIP(r), s.scheme(r), s.host(r); ip != "198.51.100.2" || scheme != "http" || host != r.Host {
+		t.Fatalf("unexpected client %s %s %s", ip, scheme, host)
+	}
+}
+
+func TestRedirectRelative(t *testing.T) {
+	s := newTestServer(t, func(s *Server) {
+		s.Route("/old", func(ctx *HttpCtx) interface{} {
+			return ctx.RedirectResult("/new", false)
+		})
+	})
+	r := httptest.NewRequest("GET", "/old", nil)
+	r.Host = "attacker.example"
+	if loc := serve(s, r).Header().Get("Location"); loc != "/new" {
+		t.Fatalf("unexpected location %q", loc)
+	}
+}
@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/simbory/mego"
+	"github.com/simbory/mego/assert"
+	"github.com/simbory/mego/session"
+)
+
+// KeyFunc get the key that the requests are limited by
+type KeyFunc func(ctx *mego.HttpCtx) string
+
+// ByIP limit the requests by the client IP address
+func ByIP(ctx *mego.HttpCtx) string {
+	return ctx.ClientIP()
+}
+
+// BySession limit the requests by the session ID of the session manager
+func BySession(manager *session.Manager) KeyFunc {
+	assert.NotNil("manager", manager)
+	return func(ctx *mego.HttpCtx) string {
+		store := manager.Start(ctx)
+		if store == nil {
+			return ""
+		}
+		return store.ID()
+	}
+}
+
+// Config the rate limit config
+type Config struct {
+	// Limit the number of the requests allowed in Period
+	Limit int
+	// Period the period that the Limit applies to, the default value is 1 minute
+	Period time.Duration
+	// Burst the maximum number of the requests that are allowed at once, the default value is Limit
+	Burst int
+	// Key the func to get the key that the requests are limited by, the default value is ByIP
+	Key KeyFunc
+	// Store the token bucket store. The in-memory store that is shared by the limiters is used if it's nil
+	Store Store
+}
+
+var limiterId uint64
+
+// New create a new token bucket rate limiter. the returned func can be used as the hijack handler of
+// Server.HijackRequest and Area.HijackRequest. The requests over the limit are ended with the status
+// code 429, and the 'Retry-After' and 'X-RateLimit-*' headers are sent to the client.
+func New(config *Config) func(*mego.HttpCtx) {
+	assert.NotNil("config", config)
+	assert.Assert("config.Limit", func() bool {
+		return config.Limit > 0
+	})
+	period := config.Period
+	if period <= 0 {
+		period = time.Minute
+	}
+	burst := config.Burst
+	if burst <= 0 {
+		burst = config.Limit
+	}
+	keyFunc := config.Key
+	if keyFunc == nil {
+		keyFunc = ByIP
+	}
+	store := config.Store
+	if store == nil {
+		store = sharedStore()
+	}
+	rate := float64(config.Limit) / period.Seconds()
+	prefix := fmt.Sprintf("%d:", atomic.AddUint64(&limiterId, 1))
+	return func(ctx *mego.HttpCtx) {
+		key := keyFunc(ctx)
+		if len(key) == 0 {
+			return
+		}
+		ok, remaining, retryAfter := store.Take(prefix+key, rate, burst)
+		h := ctx.Response().Header()
+		h.Set("X-RateLimit-Limit", strconv.Itoa(config.Limit))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		reset := time.Duration(float64(burst-remaining) / rate * float64(time.Second))
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(reset).Unix(), 10))
+		if ok {
+			return
+		}
+		seconds := int64(math.Ceil(retryAfter.Seconds()))
+		h.Set("Retry-After", strconv.FormatInt(seconds, 10))
+		writeError(ctx, http.StatusTooManyRequests)
+	}
+}
+
+// MaxInFlight create a concurrency limiter that allows at most n requests to be processed at the same time.
+// the returned func can be used as the hijack handler of Server.HijackRequest and Area.HijackRequest.
+// The requests over the limit are ended with the status code 503
+func MaxInFlight(n int) func(*mego.HttpCtx) {
+	assert.Assert("n", func() bool {
+		return n > 0
+	})
+	slots := make(chan struct{}, n)
+	return func(ctx *mego.HttpCtx) {
+		select {
+		case slots <- struct{}{}:
+			ctx.OnEnd(func() {
+				<-slots
+			})
+		default:
+			ctx.Response().Header().Set("Retry-After", "1")
+			writeError(ctx, http.StatusServiceUnavailable)
+		}
+	}
+}
+
+func writeError(ctx *mego.HttpCtx, code int) {
+	w := ctx.Response()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(code)
+	w.Write([]byte(http.StatusText(code)))
+	ctx.End()
+}
@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Store the token bucket state store interface
+type Store interface {
+	// Take take a token from the bucket of the key. The bucket is refilled with 'rate' tokens per second
+	// and holds at most 'burst' tokens. It returns whether the token is taken, the remaining tokens
+	// and the duration to wait until the next token is available
+	Take(key string, rate float64, burst int) (ok bool, remaining int, retryAfter time.Duration)
+}
+
+// bucket the token bucket
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rate   float64
+	burst  int
+}
+
+// refilled check if the bucket is full again at the time, so it's the same as the new bucket
+func (b *bucket) refilled(now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= float64(b.burst)
+}
+
+// MemoryStore the in-memory token bucket store. The buckets that have been refilled are removed by the gc timer
+// until the store is closed
+type MemoryStore struct {
+	lock    sync.Mutex
+	buckets map[string]*bucket
+	timer   *time.Timer
+	closed  bool
+}
+
+// Take take a token from the bucket of the key
+func (ms *MemoryStore) Take(key string, rate float64, burst int) (bool, int, time.Duration) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	now := time.Now()
+	b, ok := ms.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		ms.buckets[key] = b
+	} else {
+		b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+		b.last = now
+	}
+	b.rate, b.burst = rate, burst
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return false, 0, wait
+	}
+	b.tokens--
+	return true, int(b.tokens), 0
+}
+
+// gc remove the buckets that have been refilled. the full refilled buckets are the same as the new buckets
+func (ms *MemoryStore) gc(interval time.Duration) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	if ms.closed {
+		return
+	}
+	now := time.Now()
+	for key, b := range ms.buckets {
+		if b.refilled(now) {
+			delete(ms.buckets, key)
+		}
+	}
+	ms.timer = time.AfterFunc(interval, func() {
+		ms.gc(interval)
+	})
+}
+
+// Close stop the gc timer of the store, so the store can be released. The tokens can still be taken from the
+// closed store, but the buckets are not removed anymore
+func (ms *MemoryStore) Close() {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	ms.closed = true
+	if ms.timer != nil {
+		ms.timer.Stop()
+	}
+}
+
+// NewMemoryStore create a new in-memory token bucket store. The buckets that have been refilled
+// are removed every gcInterval until the store is closed
+func NewMemoryStore(gcInterval time.Duration) *MemoryStore {
+	if gcInterval <= 0 {
+		gcInterval = 10 * time.Minute
+	}
+	ms := &MemoryStore{buckets: make(map[string]*bucket)}
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	ms.timer = time.AfterFunc(gcInterval, func() {
+		ms.gc(gcInterval)
+	})
+	return ms
+}
+
+var (
+	defaultStore     *MemoryStore
+	defaultStoreOnce sync.Once
+)
+
+// sharedStore get the in-memory store that is shared by the limiters without the store, the buckets of the
+// limiters are separated by the key prefix
+func sharedStore() *MemoryStore {
+	defaultStoreOnce.Do(func() {
+		defaultStore = NewMemoryStore(0)
+	})
+	return defaultStore
+}
@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// bucketCount get the number of the buckets in the store
+func (ms *MemoryStore) bucketCount() int {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	return len(ms.buckets)
+}
+
+func TestTake(t *testing.T) {
+	ms := NewMemoryStore(0)
+	defer ms.Close()
+	for i := 1; i >= 0; i-- {
+		ok, remaining, _ := ms.Take("k", 1, 2)
+		if !ok || remaining != i {
+			t.Fatalf("unexpected result %v %d", ok, remaining)
+		}
+	}
+	ok, _, retryAfter := ms.Take("k", 1, 2)
+	if ok || retryAfter <= 0 || retryAfter > time.Second {
+		t.Fatalf("unexpected result %v %v", ok, retryAfter)
+	}
+	if ok, _, _ := ms.Take("other", 1, 2); !ok {
+		t.Fatal("the buckets of the keys are not separated")
+	}
+}
+
+func TestGC(t *testing.T) {
+	ms := NewMemoryStore(10 * time.Millisecond)
+	defer ms.Close()
+	ms.Take("refilled", 1000, 1)
+	ms.Take("empty", 0.001, 1)
+	time.Sleep(50 * time.Millisecond)
+	if n := ms.bucketCount(); n != 1 {
+		t.Fatalf("unexpected number of the buckets %d", n)
+	}
+}
+
+func TestClose(t *testing.T) {
+	ms := NewMemoryStore(10 * time.Millisecond)
+	ms.Close()
+	ms.Take("refilled", 1000, 1)
+	time.Sleep(50 * time.Millisecond)
+	if n := ms.bucketCount(); n != 1 {
+		t.Fatal("the gc timer is not stopped")
+	}
+	if ok, _, _ := ms.Take("k", 1, 1); !ok {
+		t.Fatal("the token is not taken from the closed store")
+	}
+}
@@ -72,7 +72,9 @@ func (b *BufResult) AddHeader(key, value string) {
 	b.headers[key] = value
 }
 
-// ExecResult write the data in the result buffer to the response writer
+// ExecResult write the data in the result buffer to the response writer.
+// the strong ETag of the buffered content is computed automatically for the status code 200, and the
+// status code 304 is written if the client's copy is fresh
 func (b *BufResult) ExecResult(w http.ResponseWriter, r *http.Request) {
 	if len(b.headers) > 0 {
 		for key, value := range b.headers {
@@ -89,6 +91,15 @@ func (b *BufResult) ExecResult(w http.ResponseWriter, r *http.Request) {
 	if b.StatusCode <= 0 {
 		b.StatusCode = 200
 	}
+	if b.StatusCode == 200 {
+		var content []byte
+		if b.buf != nil {
+			content = b.buf.Bytes()
+		}
+		if checkNotModified(w, r, content) {
+			return
+		}
+	}
 	w.WriteHeader(b.StatusCode)
 	if b.buf != nil {
 		io.Copy(w, b.buf)
@@ -100,6 +111,11 @@ func NewBufResult(buf *bytes.Buffer) *BufResult {
 	return &BufResult{buf: buf}
 }
 
+// newTextResult create a new buffer result with the content and the content type
+func newTextResult(content []byte, contentType string) *BufResult {
+	return &BufResult{buf: bytes.NewBuffer(content), ContentType: contentType}
+}
+
 // emptyResult the empty buffer result
 type emptyResult struct{}
 
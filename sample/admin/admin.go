@@ -4,9 +4,15 @@ import (
 	"fmt"
 	"github.com/simbory/mego"
 	"github.com/simbory/mego/assert"
+	"github.com/simbory/mego/auth"
+	"github.com/simbory/mego/csrf"
+	"github.com/simbory/mego/ratelimit"
 	"github.com/simbory/mego/session"
 	"github.com/simbory/mego/session/memory"
+	"github.com/simbory/mego/tus"
+	"os"
 	"strings"
+	"time"
 )
 
 type handleUpload struct {
@@ -18,11 +24,19 @@ func (upload *handleUpload) Get(ctx *mego.HttpCtx) interface{} {
 }
 
 func (upload *handleUpload) Post(ctx *mego.HttpCtx) interface{} {
-	file := ctx.PostFile("file")
-	filePath := ctx.MapContentPath(file.FileName)
-	err := file.SaveAndClose(filePath)
+	files, err := ctx.PostFiles("file")
 	assert.PanicErr(err)
-	return fmt.Sprintf("file Size: %d", file.Size)
+	var result []string
+	for _, file := range files {
+		// the file name is sanitized, so it cannot escape the content root
+		err = file.SaveAndClose(ctx.MapContentPath(file.FileName))
+		if err != nil {
+			result = append(result, fmt.Sprintf("%s: %s", file.FileName, err.Error()))
+			continue
+		}
+		result = append(result, fmt.Sprintf("%s: size %d, sha256 %s", file.FileName, file.Size, file.SHA256))
+	}
+	return strings.Join(result, "\n")
 }
 
 type handleLogin struct {
@@ -36,36 +50,60 @@ func (login *handleLogin) Post(ctx *mego.HttpCtx) interface{} {
 	user := ctx.Request().FormValue("username")
 	pwd := ctx.Request().FormValue("pwd")
 	if user != "test" || pwd != "test" {
-		return ctx.ViewResult("login", nil)
+		ctx.Flash("error", "invalid user name or password")
+		return ctx.RedirectResult(ctx.Request().URL.RequestURI(), false)
 	}
 	var returnUrl = ctx.Request().URL.Query().Get("returnUrl")
 	if len(returnUrl) == 0 || !strings.HasPrefix(returnUrl, "/admin/") {
 		returnUrl = "/admin/shell"
 	}
-	sessionManager.Start(ctx).Set("admin-user", "test")
+	err := loginScheme.SignIn(ctx, &auth.User{UserName: user, Roles: []string{"admin"}})
+	assert.PanicErr(err)
 	return ctx.RedirectResult(returnUrl, false)
 }
 
 var area *mego.Area
 var sessionManager *session.Manager
+var loginScheme *auth.SessionScheme
 
 func Init(server *mego.Server) {
 	area = server.GetArea("admin")
 	area.Route("/shell/upload", &handleUpload{})
+	server.SetUploadOption(&mego.UploadOption{MaxFileSize: 10 << 20})
+	// the large files are uploaded by the resumable upload endpoint
+	var uploads *tus.Handler
+	uploads = tus.New(server, &tus.Config{
+		Expiration: 24 * time.Hour,
+		OnComplete: func(ctx *mego.HttpCtx, upload *tus.Upload) {
+			// the upload is removed even if it cannot be saved, so the failed hook is not executed again and again
+			defer uploads.Remove(upload.ID)
+			file, err := upload.UploadFile()
+			assert.PanicErr(err)
+			// the existing file is not overwritten, the upload id makes the file name unique
+			filePath := ctx.MapContentPath(file.FileName)
+			if _, err = os.Stat(filePath); err == nil {
+				filePath = ctx.MapContentPath(upload.ID + "-" + file.FileName)
+			}
+			assert.PanicErr(file.SaveAndClose(filePath))
+		},
+	})
+	area.Route("/shell/files", uploads)
+	area.Route("/shell/files/*pathInfo", uploads)
 	area.Route("/login", &handleLogin{})
 
 	provider := memory.NewProvider()
 	config := &session.Config{
 		CookiePath: "/admin/",
 		CookieName: "ADMIN_SESSION_ID",
+		SignID:     true,
 	}
 	sessionManager = session.CreateManager(config, provider)
+	area.HijackRequest("/login", ratelimit.New(&ratelimit.Config{Limit: 10, Period: time.Minute}))
+	csrf.New(&csrf.Config{Session: sessionManager}).Protect(area, "/login")
 
-	area.HijackRequest("/shell/", func(ctx *mego.HttpCtx) {
-		s := sessionManager.Start(ctx)
-		userData := s.Get("admin-user")
-		if userData == nil {
-			ctx.Redirect("/admin/login?returnUrl="+ctx.Request().URL.Path, false)
-		}
-	})
+	loginScheme = auth.Session(sessionManager, "admin-user")
+	authenticator := auth.New(loginScheme)
+	authenticator.LoginURL = "/admin/login"
+	authenticator.Guard(area, "/shell/", "admin")
+	area.HijackRequest("/shell/upload", ratelimit.MaxInFlight(4))
 }
@@ -1,26 +1,55 @@
 package main
 
 import (
+	"crypto/rand"
 	"github.com/simbory/mego"
+	"github.com/simbory/mego/assert"
 	"github.com/simbory/mego/cache"
 	"github.com/simbory/mego/sample/admin"
 	"github.com/simbory/mego/sample/filters"
 	"github.com/simbory/mego/sample/handlers"
 	"github.com/simbory/mego/session"
 	"github.com/simbory/mego/session/disk"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 )
 
 func main() {
-	server := mego.NewServer(mego.WorkingDir(), ":8080")
+	setup(":8080").Run()
+}
+
+// setup create the sample server and register the handlers, the filters and the areas
+func setup(addr string) *mego.Server {
+	server := mego.NewServer(mego.WorkingDir(), addr)
+	// the signed session ids of the admin area are valid after the server is restarted
+	server.SetCookieKeys(cookieKey(server.MapRootPath("/temp/cookie.key")))
 
 	cache.UseDefault()
 	provider := disk.NewProvider(server.MapRootPath("/temp/sessions"))
 	mgr := session.CreateManager(nil, provider)
 	session.UseAsDefault(mgr)
+	server.UseFlashStore(session.NewFlashStore(mgr))
 
 	handlers.Init(server)
 	filters.Init(server)
 	admin.Init(server)
 
-	server.Run()
+	server.EnableAssets(nil)
+	server.PrecompileViews()
+	return server
+}
+
+// cookieKey read the cookie key from the file, the random key is generated and saved if the file does not exist
+func cookieKey(file string) []byte {
+	key, err := ioutil.ReadFile(file)
+	if err == nil && len(key) > 0 {
+		return key
+	}
+	key = make([]byte, 32)
+	_, err = rand.Read(key)
+	assert.PanicErr(err)
+	assert.PanicErr(os.MkdirAll(filepath.Dir(file), 0700))
+	assert.PanicErr(ioutil.WriteFile(file, key, 0600))
+	return key
 }
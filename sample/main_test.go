@@ -0,0 +1,10 @@
+package main
+
+import "testing"
+
+func TestViewsPrecompile(t *testing.T) {
+	server := setup(":0")
+	if err := server.CompileViews(); err != nil {
+		t.Fatal(err)
+	}
+}
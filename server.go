@@ -5,13 +5,15 @@ import (
 	"encoding/xml"
 	"errors"
 	"github.com/simbory/mego/assert"
+	"io/fs"
+	"log"
+	"net"
 	"net/http"
 	"net/url"
-	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
-	"path/filepath"
 )
 
 type routeSetting struct {
@@ -33,12 +35,27 @@ type Server struct {
 	err500Handler ErrHandler
 	err400Handler http.HandlerFunc
 	err403Handler http.HandlerFunc
+	err413Handler http.HandlerFunc
 	hijackColl    hijackContainer
 	routeSettings []*routeSetting
 	viewEngine    *ViewEngine
 	engineLock    sync.RWMutex
 	ctxId         uint64
-	serverVar     map[string]interface{}
+	serverVar     map[string]interface {
+	}
+	outputCache    outputCache
+	cors           *corsPolicy
+	trustedProxies []*net.IPNet
+	keyRing        *KeyRing
+	keyRingLock    sync.Mutex
+	cookieKeys     bool
+	flashStore     FlashStore
+	webFS          fs.FS
+	precompile     bool
+	assets         *assetPipeline
+	staticOpt      StaticOption
+	staticMounts   []*staticMount
+	uploadOpt      UploadOption
 }
 
 // assertUnlocked assert that the server is not running
@@ -78,27 +95,12 @@ func (s *Server) onInit() {
 			s.routing.addRoute(setting.routePath, setting.area, setting.processor)
 		}
 	}
-}
-
-func (s *Server) processStaticRequest(w http.ResponseWriter, r *http.Request) {
-	filePath := s.MapContentPath(r.URL.Path)
-	stat, err := os.Stat(filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			s.err404Handler(w, r)
-		} else {
-			s.err403Handler(w, r)
-		}
-		return
-	}
-	if !stat.IsDir() {
-		http.ServeFile(w, r, filePath)
-	} else {
-		s.err404Handler(w, r)
+	if s.precompile {
+		assert.PanicErr(s.CompileViews())
 	}
 }
 
-func findHandler(handler interface{}, method string) (func(ctx *HttpCtx)interface{}, bool) {
+func findHandler(handler interface{}, method string) (func(ctx *HttpCtx) interface{}, bool) {
 	switch method {
 	case "GET":
 		h, ok := handler.(RouteGet)
@@ -189,16 +191,30 @@ func findHandler(handler interface{}, method string) (func(ctx *HttpCtx)interfac
 	}
 }
 
-func (s *Server) processDynamicRequest(w http.ResponseWriter, r *http.Request, urlPath string) interface{} {
+func (s *Server) processDynamicRequest(ctx *HttpCtx, urlPath string) interface{} {
+	w, r := ctx.res, ctx.req
 	method := strings.ToUpper(r.Method)
 	handler, routeData, area, err := s.routing.lookup(urlPath)
 	assert.PanicErr(err)
-	var processor func(ctx *HttpCtx)interface{}
+	var processor func(ctx *HttpCtx) interface{}
 	var filterFunc func(ctx *HttpCtx)
 	if handler == nil {
 		return nil
 	}
-	handlerFunc,ok := handler.(func(ctx *HttpCtx)interface{})
+	policy := s.cors
+	if area != nil && area.cors != nil {
+		policy = area.cors
+	}
+	if policy != nil {
+		if isPreflight(r) {
+			if !policy.preflight(w, r) {
+				s.err403Handler(w, r)
+			}
+			return &emptyResult{}
+		}
+		policy.actual(w, r)
+	}
+	handlerFunc, ok := handler.(func(ctx *HttpCtx) interface{})
 	if ok {
 		processor = handlerFunc
 	} else {
@@ -214,15 +230,8 @@ func (s *Server) processDynamicRequest(w http.ResponseWriter, r *http.Request, u
 		}
 	}
 	if processor != nil {
-		ctxId := atomic.AddUint64(&(s.ctxId), 1)
-		var ctx = &HttpCtx{
-			req:       r,
-			res:       w,
-			routeData: routeData,
-			Server:    s,
-			area:      area,
-			ctxId:     ctxId,
-		}
+		ctx.routeData = routeData
+		ctx.area = area
 		if area != nil {
 			area.hijackColl.exec(urlPath, ctx)
 		} else {
@@ -237,6 +246,11 @@ func (s *Server) processDynamicRequest(w http.ResponseWriter, r *http.Request, u
 				return &emptyResult{}
 			}
 		}
+		// the output cache is looked up after the hijackers and the filter, so the cached responses are not
+		// sent to the requests that are rejected by them
+		if s.serveOutputCache(ctx, urlPath) {
+			return &emptyResult{}
+		}
 		return processor(ctx)
 	}
 	return nil
@@ -252,17 +266,13 @@ func (s *Server) flush(w http.ResponseWriter, req *http.Request, result interfac
 		http.Redirect(w, req, res.String(), 302)
 		return
 	case string:
-		content := result.(string)
-		w.Header().Add("Content-Type", "text/plain; charset=utf-8")
-		w.Write(str2Byte(content))
+		newTextResult(str2Byte(result.(string)), "text/plain").ExecResult(w, req)
 		return
 	case []byte:
-		w.Header().Add("Content-Type", "text/plain; charset=utf-8")
-		w.Write(result.([]byte))
+		newTextResult(result.([]byte), "text/plain").ExecResult(w, req)
 		return
 	case byte:
-		w.Header().Add("Content-Type", "text/plain; charset=utf-8")
-		w.Write([]byte{result.(byte)})
+		newTextResult([]byte{result.(byte)}, "text/plain").ExecResult(w, req)
 		return
 	default:
 		var cType = req.Header.Get("Content-Type")
@@ -271,13 +281,12 @@ func (s *Server) flush(w http.ResponseWriter, req *http.Request, result interfac
 		if cType == "text/xml" {
 			contentBytes, err = xml.Marshal(result)
 			assert.PanicErr(err)
-			w.Header().Add("Content-Type", "text/xml; charset=utf-8")
+			newTextResult(contentBytes, "text/xml").ExecResult(w, req)
 		} else {
 			contentBytes, err = json.Marshal(result)
 			assert.PanicErr(err)
-			w.Header().Add("Content-Type", "application/json; charset=utf-8")
+			newTextResult(contentBytes, "application/json").ExecResult(w, req)
 		}
-		w.Write(contentBytes)
 	}
 }
 
@@ -286,7 +295,7 @@ func (s *Server) initViewEngine() {
 		s.engineLock.Lock()
 		defer s.engineLock.Unlock()
 		if s.viewEngine == nil {
-			s.viewEngine = NewViewEngine(s.MapRootPath("views"))
+			s.viewEngine = s.newViewEngine("views")
 		}
 	}
 }
@@ -296,6 +305,26 @@ func (s *Server) isDynamic(cleanUrlPath string) bool {
 	return len(ext) == 0
 }
 
+// processDynamic route the request to the handler and write the result. It returns false if there is no handler
+func (s *Server) processDynamic(w http.ResponseWriter, r *http.Request, urlPath string) bool {
+	ctx := &HttpCtx{
+		req:    r,
+		res:    w,
+		Server: s,
+		ctxId:  atomic.AddUint64(&(s.ctxId), 1),
+	}
+	defer ctx.execEndHandlers()
+	ctx.OnBeforeWrite(ctx.dropFlashes)
+	var result = s.processDynamicRequest(ctx, urlPath)
+	if result == nil {
+		return false
+	}
+	// the response writer of the context may be wrapped by OnBeforeWrite
+	s.flush(ctx.res, r, result)
+	s.storeOutputCache(ctx, result)
+	return true
+}
+
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// catch the panic error
 	defer func() {
@@ -306,6 +335,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		if _, ok := rec.(*endCtxSignal); ok {
 			return
 		}
+		log.Printf("mego: panic serving %s %s for %s: %v", r.Method, r.URL.Path, s.clientIP(r), rec)
 		s.err500Handler(w, r, rec)
 		rec1 := recover()
 		if rec1 != nil {
@@ -316,15 +346,13 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if len(urlPath) == 0 {
 		urlPath = "/"
 	}
-	isDynamic := s.isDynamic(urlPath)
-	if isDynamic {
-		var result = s.processDynamicRequest(w, r, urlPath)
-		if result != nil {
-			s.flush(w, r, result)
-		} else {
+	// the paths without file extension are routed first, and the paths with file extension are served
+	// as the static files first. the fallback file of the single page application is served at last
+	if s.isDynamic(urlPath) {
+		if !s.processDynamic(w, r, urlPath) && !s.processStaticRequest(w, r) && !s.processStaticFallback(w, r) {
 			s.err404Handler(w, r)
 		}
-	} else {
-		s.processStaticRequest(w, r)
+	} else if !s.processStaticRequest(w, r) && !s.processDynamic(w, r, urlPath) {
+		s.err404Handler(w, r)
 	}
 }
@@ -0,0 +1,224 @@
+// Package cookie implements the session provider that keeps the session data in the encrypted cookies, so the
+// sessions are shared by all the server instances without the server side storage.
+//
+// The session values are encoded by gob (see session.RegisterType), encrypted and authenticated with AES-GCM by
+// the key ring, and the large session data is split into multiple cookies. The cookies are written before the
+// response headers are written.
+package cookie
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/simbory/mego"
+	"github.com/simbory/mego/session"
+)
+
+// Config the config of the cookie session provider
+type Config struct {
+	// CookieName the name of the cookie that keeps the session data, the default value is 'SESSION_DATA'.
+	// The chunks of the large session data are kept in the cookies 'SESSION_DATA_1', 'SESSION_DATA_2' ...
+	CookieName string
+	// Keys the key ring that encrypts the session data, the default key ring is the server key ring (see
+	// mego.Server.SetCookieKeys). The old keys of the key ring are still accepted, so the keys can be rotated
+	Keys *mego.KeyRing
+	// ChunkSize the max size of each cookie value, the default value is 3800
+	ChunkSize int
+	// MaxChunks the max number of the cookies, the default value is 4. Storage.Set returns ErrTooLarge if the
+	// session data does not fit in the cookies
+	MaxChunks int
+}
+
+// provider Implement the session.ContextProvider interface
+type provider struct {
+	config Config
+}
+
+func (prov *provider) cookieName() string {
+	if len(prov.config.CookieName) == 0 {
+		return "SESSION_DATA"
+	}
+	return prov.config.CookieName
+}
+
+func (prov *provider) chunkSize() int {
+	if prov.config.ChunkSize <= 0 {
+		return 3800
+	}
+	return prov.config.ChunkSize
+}
+
+func (prov *provider) maxChunks() int {
+	if prov.config.MaxChunks <= 0 {
+		return 4
+	}
+	return prov.config.MaxChunks
+}
+
+func (prov *provider) keys(ctx *mego.HttpCtx) *mego.KeyRing {
+	if prov.config.Keys != nil {
+		return prov.config.Keys
+	}
+	return ctx.Server.KeyRing()
+}
+
+// ctxKey the key of the session storage in the http context
+func (prov *provider) ctxKey() string {
+	return "session/cookie:" + prov.cookieName()
+}
+
+// readCookie get the encrypted value of the cookies and the number of the chunk cookies
+func (prov *provider) readCookie(ctx *mego.HttpCtx) (string, int) {
+	name := prov.cookieName()
+	c := ctx.Cookie(name)
+	if c == nil {
+		return "", 0
+	}
+	count, err := strconv.Atoi(c.Value)
+	if err != nil {
+		return c.Value, 0
+	}
+	if count <= 0 || count > prov.maxChunks() {
+		return "", 0
+	}
+	buf := &bytes.Buffer{}
+	for i := 1; i <= count; i++ {
+		chunk := ctx.Cookie(chunkName(name, i))
+		if chunk == nil {
+			return "", count
+		}
+		buf.WriteString(chunk.Value)
+	}
+	return buf.String(), count
+}
+
+// load decrypt and decode the session data of the cookies
+func (prov *provider) load(ctx *mego.HttpCtx, value string) *payload {
+	if len(value) == 0 {
+		return nil
+	}
+	data, ok := prov.keys(ctx).Decrypt(prov.cookieName(), value)
+	if !ok {
+		return nil
+	}
+	p := &payload{}
+	if gob.NewDecoder(bytes.NewReader(data)).Decode(p) != nil {
+		return nil
+	}
+	return p
+}
+
+// ReadCtx get the session storage from the cookies. The storage is shared by the http context, and it's saved
+// to the cookies before the response is written
+func (prov *provider) ReadCtx(ctx *mego.HttpCtx, sid string, config *session.Config) (session.Storage, bool) {
+	if st, ok := ctx.GetCtxItem(prov.ctxKey()).(*storage); ok {
+		st.lock.Lock()
+		defer st.lock.Unlock()
+		// the session that is read again after it's destroyed is a new session, for example after the logout and
+		// the login in the same request
+		if st.sid != sid || st.destroyed {
+			st.sid = sid
+			st.values = make(map[string]interface{})
+			st.loaded = false
+			st.destroyed = false
+			st.dirty = true
+		}
+		return st, st.loaded
+	}
+	value, chunks := prov.readCookie(ctx)
+	st := &storage{
+		prov:    prov,
+		ctx:     ctx,
+		config:  config,
+		sid:     sid,
+		values:  make(map[string]interface{}),
+		chunks:  chunks,
+		present: ctx.Cookie(prov.cookieName()) != nil,
+	}
+	if p := prov.load(ctx, value); p != nil && p.ID == sid && time.Now().Before(time.Unix(p.Expires, 0)) {
+		if p.Values != nil {
+			st.values = p.Values
+		}
+		st.expires = time.Unix(p.Expires, 0)
+		st.loaded = true
+	} else {
+		// the new session is saved, so it's found by the next request
+		st.dirty = true
+	}
+	ctx.SetCtxItem(prov.ctxKey(), st)
+	ctx.OnBeforeWrite(st.save)
+	return st, st.loaded
+}
+
+// RegenerateCtx change the session id, the session values are kept
+func (prov *provider) RegenerateCtx(ctx *mego.HttpCtx, oldSid, sid string, config *session.Config) session.Storage {
+	st, _ := prov.ReadCtx(ctx, oldSid, config)
+	s := st.(*storage)
+	s.lock.Lock()
+	s.sid = sid
+	s.dirty = true
+	s.lock.Unlock()
+	return st
+}
+
+// DestroyCtx delete the session cookies
+func (prov *provider) DestroyCtx(ctx *mego.HttpCtx, sid string, config *session.Config) {
+	st, _ := prov.ReadCtx(ctx, sid, config)
+	s := st.(*storage)
+	s.lock.Lock()
+	s.values = make(map[string]interface{})
+	s.destroyed = true
+	s.lock.Unlock()
+}
+
+// Init init cookie session
+func (prov *provider) Init(gcLifetime int64, config string) error {
+	return nil
+}
+
+// Read create an empty session store, the session data is only available by ReadCtx
+func (prov *provider) Read(sid string) session.Storage {
+	return &storage{prov: prov, sid: sid, values: make(map[string]interface{})}
+}
+
+// Exist the session data is only available by ReadCtx
+func (prov *provider) Exist(sid string) bool {
+	return false
+}
+
+// Regenerate the session data is only available by RegenerateCtx
+func (prov *provider) Regenerate(oldSid, sid string) (session.Storage, error) {
+	return nil, errors.New("cookie session: the session id is regenerated by RegenerateCtx")
+}
+
+// Destroy the session data is deleted by DestroyCtx
+func (prov *provider) Destroy(sid string) error {
+	return nil
+}
+
+// All the number of the cookie sessions is unknown
+func (prov *provider) All() int {
+	return 0
+}
+
+// Update the session data is updated when it's saved
+func (prov *provider) Update(sid string) error {
+	return nil
+}
+
+// GC the expired cookie sessions are rejected when they are read
+func (prov *provider) GC() {
+}
+
+// NewProvider create the cookie session provider. The config can be nil
+func NewProvider(config *Config) session.ContextProvider {
+	prov := &provider{}
+	if config != nil {
+		prov.config = *config
+	}
+	mego.RegisterPrivateCookie(prov.cookieName())
+	return prov
+}
@@ -0,0 +1,205 @@
+package cookie
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/simbory/mego"
+	"github.com/simbory/mego/session"
+)
+
+// ErrTooLarge the error of the session data that does not fit in the cookies
+var ErrTooLarge = errors.New("cookie session: the session data is too large for the cookies")
+
+// gcmOverhead the size of the nonce and the tag of the AES-GCM encryption
+const gcmOverhead = 12 + 16
+
+// payload the encrypted content of the session cookie
+type payload struct {
+	ID      string
+	Expires int64
+	Values  map[string]interface{}
+}
+
+// storage cookie session store.
+// it keeps the session values in the encrypted cookies of the http context.
+type storage struct {
+	prov      *provider
+	ctx       *mego.HttpCtx
+	config    *session.Config
+	sid       string
+	values    map[string]interface{}
+	expires   time.Time
+	loaded    bool
+	dirty     bool
+	destroyed bool
+	// chunks the number of the chunk cookies in the request, the stale chunks are deleted when the cookie is saved
+	chunks int
+	// present indicates that the session cookie is sent by the request, it's deleted if the session is destroyed
+	present bool
+	lock    sync.RWMutex
+}
+
+// encode encode the session values with gob
+func (st *storage) encode(expires time.Time) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := gob.NewEncoder(buf).Encode(&payload{ID: st.sid, Expires: expires.Unix(), Values: st.values})
+	return buf.Bytes(), err
+}
+
+// fits check if the session values fit in the cookies after they are encrypted
+func (st *storage) fits() error {
+	data, err := st.encode(time.Now())
+	if err != nil {
+		return err
+	}
+	if base64.RawURLEncoding.EncodedLen(len(data)+gcmOverhead) > st.prov.chunkSize()*st.prov.maxChunks() {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+// Set Value to cookie session. It returns ErrTooLarge if the session data does not fit in the cookies
+func (st *storage) Set(key string, value interface{}) error {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	old, existed := st.values[key]
+	st.values[key] = value
+	if err := st.fits(); err != nil {
+		if existed {
+			st.values[key] = old
+		} else {
+			delete(st.values, key)
+		}
+		return err
+	}
+	st.dirty = true
+	return nil
+}
+
+// Get Value from cookie session by key
+func (st *storage) Get(key string) interface{} {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	if v, ok := st.values[key]; ok {
+		return v
+	}
+	return nil
+}
+
+// Delete in cookie session by key
+func (st *storage) Delete(key string) error {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if _, ok := st.values[key]; ok {
+		delete(st.values, key)
+		st.dirty = true
+	}
+	return nil
+}
+
+// Flush clear all values in cookie session
+func (st *storage) Flush() error {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.values = make(map[string]interface{})
+	st.dirty = true
+	return nil
+}
+
+// ID get this id of cookie session store
+func (st *storage) ID() string {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	return st.sid
+}
+
+// Release Implement method, the cookie is saved before the response is written
+func (st *storage) Release(w http.ResponseWriter) {
+}
+
+// cookie create the cookie with the attributes of the session config
+func (st *storage) cookie(name, value string) *http.Cookie {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     st.config.CookiePath,
+		Domain:   st.config.Domain,
+		HttpOnly: st.config.HTTPOnly,
+		Secure:   st.config.Secure && st.ctx.Scheme() == "https",
+	}
+	if st.config.CookieLifeTime > 0 {
+		c.MaxAge = st.config.CookieLifeTime
+		c.Expires = time.Now().Add(time.Duration(st.config.CookieLifeTime) * time.Second)
+	}
+	return c
+}
+
+// save write the encrypted session data to the cookies. It's executed before the response is written, and the
+// unchanged session is written again only if half of the lifetime is passed
+func (st *storage) save() {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	name := st.prov.cookieName()
+	if st.destroyed {
+		if st.present || st.chunks > 0 {
+			st.ctx.DeleteCookie(name, st.config.CookiePath)
+			st.deleteChunks(0)
+		}
+		return
+	}
+	lifetime := time.Duration(st.config.MaxLifetime) * time.Second
+	if !st.dirty && time.Until(st.expires) > lifetime/2 {
+		return
+	}
+	expires := time.Now().Add(lifetime)
+	data, err := st.encode(expires)
+	if err != nil {
+		log.Printf("cookie session: failed to encode the session %s: %v", st.sid, err)
+		return
+	}
+	value := st.prov.keys(st.ctx).Encrypt(name, data)
+	size := st.prov.chunkSize()
+	var parts []string
+	for len(value) > size {
+		parts = append(parts, value[:size])
+		value = value[size:]
+	}
+	parts = append(parts, value)
+	if len(parts) > st.prov.maxChunks() {
+		log.Printf("cookie session: the session %s is too large for the cookies", st.sid)
+		return
+	}
+	if len(parts) == 1 {
+		st.ctx.SetCookie(st.cookie(name, parts[0]))
+		st.deleteChunks(0)
+	} else {
+		// the first cookie keeps the number of the chunks
+		st.ctx.SetCookie(st.cookie(name, strconv.Itoa(len(parts))))
+		for i, part := range parts {
+			st.ctx.SetCookie(st.cookie(chunkName(name, i+1), part))
+		}
+		st.deleteChunks(len(parts))
+	}
+	st.expires = expires
+	st.dirty = false
+}
+
+// deleteChunks delete the chunk cookies of the request that are not used anymore
+func (st *storage) deleteChunks(used int) {
+	for i := used + 1; i <= st.chunks; i++ {
+		st.ctx.DeleteCookie(chunkName(st.prov.cookieName(), i), st.config.CookiePath)
+	}
+}
+
+// chunkName get the cookie name of the chunk i
+func chunkName(name string, i int) string {
+	return name + "_" + strconv.Itoa(i)
+}
@@ -0,0 +1,53 @@
+package session
+
+import (
+	"encoding/gob"
+
+	"github.com/simbory/mego"
+	"github.com/simbory/mego/assert"
+)
+
+// flashKey the session key that the flash messages are stored with
+const flashKey = "__flashes"
+
+// flashStore the flash message store that keeps the messages in the session
+type flashStore struct {
+	manager *Manager
+}
+
+// Load get the flash messages from the session. The session is not started if the client has no session
+func (fs *flashStore) Load(ctx *mego.HttpCtx) []*mego.Flash {
+	if ctx.GetCtxItem(fs.manager.managerID) == nil && ctx.Cookie(fs.manager.config.CookieName) == nil {
+		return nil
+	}
+	store := fs.manager.Start(ctx)
+	if store == nil {
+		return nil
+	}
+	flashes, _ := store.Get(flashKey).([]*mego.Flash)
+	return flashes
+}
+
+// Save write the flash messages to the session
+func (fs *flashStore) Save(ctx *mego.HttpCtx, flashes []*mego.Flash) {
+	store := fs.manager.Start(ctx)
+	if store == nil {
+		return
+	}
+	if len(flashes) == 0 {
+		store.Delete(flashKey)
+	} else {
+		store.Set(flashKey, flashes)
+	}
+}
+
+// NewFlashStore create the flash message store that keeps the messages in the sessions of the manager.
+// Use it with mego.Server.UseFlashStore, the store of the default manager is used if UseAsDefault is called
+func NewFlashStore(manager *Manager) mego.FlashStore {
+	assert.NotNil("manager", manager)
+	return &flashStore{manager: manager}
+}
+
+func init() {
+	gob.Register([]*mego.Flash{})
+}
@@ -1,8 +1,10 @@
 package session
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/gob"
-	"github.com/google/uuid"
+	"errors"
 	"github.com/simbory/mego"
 	"github.com/simbory/mego/assert"
 	"net/http"
@@ -22,6 +24,30 @@ type Config struct {
 	CookieLifeTime  int    `xml:"cookieLifeTime,attr"`
 	ProviderConfig  string `xml:"providerConfig,attr"`
 	Domain          string `xml:"domain,attr"`
+	// IDLength the number of the random bytes of the session id, the default value is 32
+	IDLength int `xml:"idLength,attr"`
+	// IDGenerator generate the session ids, the default generator creates the random ids of IDLength bytes by crypto/rand
+	IDGenerator IDGenerator `xml:"-"`
+	// SignID sign the session id in the cookie with the server key ring (see mego.Server.SetCookieKeys), so the
+	// forged session ids are rejected without reading the provider. The keys must be set by SetCookieKeys, the
+	// random key of the server is not used: the sessions would be lost after the server is restarted, and the
+	// instances of the server would reject the cookies of each other. The request panics if the keys are not set
+	SignID bool `xml:"signID,attr"`
+}
+
+// IDGenerator generate the new session id. The id must be unguessable
+type IDGenerator func() (string, error)
+
+// defaultIDLength the default number of the random bytes of the session id
+const defaultIDLength = 32
+
+// RandomID generate the random id of length bytes by crypto/rand, the id is encoded by the URL-safe base64 encoding
+func RandomID(length int) (string, error) {
+	b := make([]byte, length)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
 // Manager the session manager struct
@@ -43,32 +69,62 @@ func (manager *Manager) initialize() {
 	if manager.initialized {
 		return
 	}
+	if lp, ok := manager.provider.(LifetimeProvider); ok {
+		lp.SetMaxLifetime(manager.config.MaxLifetime)
+	}
 	assert.PanicErr(manager.provider.Init(manager.config.GcLifetime, manager.config.ProviderConfig))
 	go manager.gc()
 	manager.initialized = true
 }
 
-func (manager *Manager) getSessionID(r *http.Request) (string, error) {
-	cookie, errs := r.Cookie(manager.config.CookieName)
+func (manager *Manager) getSessionID(ctx *mego.HttpCtx) (string, error) {
+	cookie, errs := ctx.Request().Cookie(manager.config.CookieName)
 	if errs != nil || cookie.Value == "" || cookie.MaxAge < 0 {
 		return "", nil
 	}
+	if manager.config.SignID {
+		// the forged or tampered session id is ignored
+		id, _ := manager.keyRing(ctx).Verify(cookie.Name, cookie.Value)
+		return id, nil
+	}
 	// HTTP Request contains cookie for sessionid info.
 	return url.QueryUnescape(cookie.Value)
 }
 
-// Set cookie with https.
-func (manager *Manager) isSecure(req *http.Request) bool {
-	if !manager.config.Secure {
-		return false
+// cookieValue get the cookie value of the session id
+func (manager *Manager) cookieValue(ctx *mego.HttpCtx, id string) string {
+	if manager.config.SignID {
+		return manager.keyRing(ctx).Sign(manager.config.CookieName, id)
+	}
+	return url.QueryEscape(id)
+}
+
+// keyRing get the server key ring that signs the session id, the keys must be set by mego.Server.SetCookieKeys
+func (manager *Manager) keyRing(ctx *mego.HttpCtx) *mego.KeyRing {
+	if !ctx.Server.HasCookieKeys() {
+		panic(errors.New("session: the cookie keys must be set by mego.Server.SetCookieKeys if SignID is enabled"))
 	}
-	if req.URL.Scheme != "" {
-		return req.URL.Scheme == "https"
+	return ctx.Server.KeyRing()
+}
+
+// newID generate the new session id
+func (manager *Manager) newID() string {
+	if manager.config.IDGenerator != nil {
+		id, err := manager.config.IDGenerator()
+		assert.PanicErr(err)
+		return id
 	}
-	if req.TLS == nil {
+	id, err := RandomID(manager.config.IDLength)
+	assert.PanicErr(err)
+	return id
+}
+
+// Set cookie with https.
+func (manager *Manager) isSecure(ctx *mego.HttpCtx) bool {
+	if !manager.config.Secure {
 		return false
 	}
-	return true
+	return ctx.Scheme() == "https"
 }
 
 // gc Start session gc process.
@@ -92,22 +148,33 @@ func (manager *Manager) Start(ctx *mego.HttpCtx) Storage {
 	manager.initialize()
 	r := ctx.Request()
 	w := ctx.Response()
-	id, err := manager.getSessionID(r)
+	id, err := manager.getSessionID(ctx)
 	if err != nil {
 		return nil
 	}
-	if id != "" && manager.provider.Exist(id) {
+	cp, isCtxProvider := manager.provider.(ContextProvider)
+	if id != "" && isCtxProvider {
+		if store, ok := cp.ReadCtx(ctx, id, manager.config); ok {
+			ctx.SetCtxItem(manager.managerID, store)
+			return store
+		}
+	} else if id != "" && manager.provider.Exist(id) {
 		return manager.provider.Read(id)
 	}
 	// Generate a new store
-	id = newGuidStr()
-	store := manager.provider.Read(id)
+	id = manager.newID()
+	var store Storage
+	if isCtxProvider {
+		store, _ = cp.ReadCtx(ctx, id, manager.config)
+	} else {
+		store = manager.provider.Read(id)
+	}
 	cookie := &http.Cookie{
 		Name:     manager.config.CookieName,
-		Value:    url.QueryEscape(id),
+		Value:    manager.cookieValue(ctx, id),
 		Path:     manager.config.CookiePath,
 		HttpOnly: manager.config.HTTPOnly,
-		Secure:   manager.isSecure(r),
+		Secure:   manager.isSecure(ctx),
 	}
 	if len(manager.config.Domain) > 0 {
 		cookie.Domain = manager.config.Domain
@@ -128,17 +195,19 @@ func (manager *Manager) Start(ctx *mego.HttpCtx) Storage {
 func (manager *Manager) Destroy(ctx *mego.HttpCtx) {
 	ctx.RemoveCtxItem(manager.managerID)
 	manager.initialize()
-	r := ctx.Request()
 	w := ctx.Response()
-	cookie, err := r.Cookie(manager.config.CookieName)
-	if err != nil || cookie.Value == "" {
+	sid, _ := manager.getSessionID(ctx)
+	if sid == "" {
 		return
 	}
-	sid, _ := url.QueryUnescape(cookie.Value)
-	manager.provider.Destroy(sid)
+	if cp, ok := manager.provider.(ContextProvider); ok {
+		cp.DestroyCtx(ctx, sid, manager.config)
+	} else {
+		manager.provider.Destroy(sid)
+	}
 	if manager.config.EnableSetCookie {
 		expiration := time.Now().Add(-10000)
-		cookie = &http.Cookie{
+		cookie := &http.Cookie{
 			Name:     manager.config.CookieName,
 			Path:     manager.config.CookiePath,
 			HttpOnly: manager.config.HTTPOnly,
@@ -154,23 +223,32 @@ func (manager *Manager) RegenerateID(ctx *mego.HttpCtx) (session Storage) {
 	manager.initialize()
 	r := ctx.Request()
 	w := ctx.Response()
-	sid := newGuidStr()
+	sid := manager.newID()
+	oldSessionId, _ := manager.getSessionID(ctx)
 	cookie, err := r.Cookie(manager.config.CookieName)
-	if err != nil || cookie.Value == "" {
+	cp, isCtxProvider := manager.provider.(ContextProvider)
+	if err != nil || oldSessionId == "" {
 		//delete old cookie
-		session = manager.provider.Read(sid)
+		if isCtxProvider {
+			session, _ = cp.ReadCtx(ctx, sid, manager.config)
+		} else {
+			session = manager.provider.Read(sid)
+		}
 		cookie = &http.Cookie{
 			Name:     manager.config.CookieName,
-			Value:    url.QueryEscape(sid),
+			Value:    manager.cookieValue(ctx, sid),
 			Path:     manager.config.CookiePath,
 			HttpOnly: manager.config.HTTPOnly,
-			Secure:   manager.isSecure(r),
+			Secure:   manager.isSecure(ctx),
 			Domain:   manager.config.Domain,
 		}
 	} else {
-		oldSessionId, _ := url.QueryUnescape(cookie.Value)
-		session, _ = manager.provider.Regenerate(oldSessionId, sid)
-		cookie.Value = url.QueryEscape(sid)
+		if isCtxProvider {
+			session = cp.RegenerateCtx(ctx, oldSessionId, sid, manager.config)
+		} else {
+			session, _ = manager.provider.Regenerate(oldSessionId, sid)
+		}
+		cookie.Value = manager.cookieValue(ctx, sid)
 		cookie.HttpOnly = true
 		cookie.Path = "/"
 	}
@@ -193,9 +271,3 @@ func RegisterType(value interface{}) {
 func RegisterTypeName(name string, value interface{}) {
 	gob.RegisterName(name, value)
 }
-
-func newGuidStr() string {
-	id, err := uuid.NewUUID()
-	assert.PanicErr(err)
-	return id.String()
-}
@@ -1,5 +1,7 @@
 package session
 
+import "github.com/simbory/mego"
+
 // Provider contains global session methods and saved SessionStores.
 // it can operate a Storage by its id.
 type Provider interface {
@@ -12,3 +14,24 @@ type Provider interface {
 	Update(sid string) error
 	GC()
 }
+
+// ContextProvider the optional interface of the provider that keeps the session data in the request and the
+// response instead of the server, for example the cookie provider. The manager calls the methods of the
+// http context instead of Exist, Read, Regenerate and Destroy
+type ContextProvider interface {
+	Provider
+	// ReadCtx get the session storage of the id from the http context. If the session does not exist, a new
+	// storage is created and false is returned
+	ReadCtx(ctx *mego.HttpCtx, sid string, config *Config) (Storage, bool)
+	// RegenerateCtx change the id of the session in the http context
+	RegenerateCtx(ctx *mego.HttpCtx, oldSid, sid string, config *Config) Storage
+	// DestroyCtx destroy the session in the http context
+	DestroyCtx(ctx *mego.HttpCtx, sid string, config *Config)
+}
+
+// LifetimeProvider the optional interface of the provider that expires the sessions by itself, for example the
+// redis provider. The manager calls SetMaxLifetime with Config.MaxLifetime before Init
+type LifetimeProvider interface {
+	Provider
+	SetMaxLifetime(maxLifetime int64)
+}
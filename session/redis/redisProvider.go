@@ -0,0 +1,200 @@
+// Package redis implements the session provider that keeps the sessions in the redis server by the RESP protocol,
+// so the sessions are shared by all the server instances. Each session is saved in a hash whose TTL is the max
+// lifetime of the session, and the session values are encoded by gob (see session.RegisterType).
+//
+// The session storage cannot return the error when it's read, so Read panics if the redis server cannot be reached,
+// and a redis outage surfaces as the status 500 of every request that uses the sessions.
+package redis
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strings"
+	"time"
+
+	"github.com/simbory/mego/assert"
+	"github.com/simbory/mego/session"
+)
+
+// Config the config of the redis session provider
+type Config struct {
+	// Addr the address of the redis server, the default value is '127.0.0.1:6379'
+	Addr string
+	// Password the password of the redis server
+	Password string
+	// DB the database number
+	DB int
+	// Prefix the prefix of the session keys, the default value is 'session:'
+	Prefix string
+	// PoolSize the max number of the idle connections, the default value is 10
+	PoolSize int
+	// DialTimeout the timeout of connecting to the redis server, the default value is 5 seconds
+	DialTimeout time.Duration
+	// Timeout the read and write timeout of each command, the default value is 5 seconds
+	Timeout time.Duration
+}
+
+const (
+	// createdField the hash field that keeps the creation time, so the hash of the empty session exists
+	createdField = "_created"
+	// valuePrefix the prefix of the hash fields of the session values
+	valuePrefix = "v:"
+)
+
+// provider Implement the provider interface
+type provider struct {
+	config      Config
+	pool        *pool
+	maxLifetime int64
+}
+
+func (prov *provider) key(sid string) string {
+	prefix := prov.config.Prefix
+	if len(prefix) == 0 {
+		prefix = "session:"
+	}
+	return prefix + sid
+}
+
+// createCommand get the command that creates the hash of the empty session
+func (prov *provider) createCommand(key string) []interface{} {
+	return []interface{}{"HSET", key, createdField, time.Now().Unix()}
+}
+
+func encodeValue(value interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := gob.NewEncoder(buf).Encode(&value)
+	return buf.Bytes(), err
+}
+
+func decodeValue(data []byte) (interface{}, error) {
+	var value interface{}
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, err
+}
+
+// SetMaxLifetime set the TTL of the session hashes, it's called by the session manager with Config.MaxLifetime
+func (prov *provider) SetMaxLifetime(maxLifetime int64) {
+	prov.maxLifetime = maxLifetime
+}
+
+// Init init redis session
+func (prov *provider) Init(gcLifetime int64, config string) error {
+	if prov.maxLifetime <= 0 {
+		prov.maxLifetime = gcLifetime
+	}
+	reply, err := prov.pool.do("PING")
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return redisError("unexpected reply of PING")
+	}
+	return nil
+}
+
+// Read get the session store by sid, the new session is created if it does not exist. It panics if the command fails
+func (prov *provider) Read(sid string) session.Storage {
+	k := prov.key(sid)
+	replies, err := prov.pool.pipeline(
+		[]interface{}{"HGETALL", k},
+		[]interface{}{"EXPIRE", k, prov.maxLifetime},
+	)
+	assert.PanicErr(err)
+	st := &storage{prov: prov, sid: sid, value: make(map[string]interface{})}
+	fields, _ := replies[0].([]interface{})
+	if len(fields) == 0 {
+		_, err = prov.pool.pipeline(prov.createCommand(k), []interface{}{"EXPIRE", k, prov.maxLifetime})
+		assert.PanicErr(err)
+		return st
+	}
+	for i := 0; i+1 < len(fields); i += 2 {
+		name, _ := fields[i].([]byte)
+		data, _ := fields[i+1].([]byte)
+		if !strings.HasPrefix(string(name), valuePrefix) {
+			continue
+		}
+		if value, err := decodeValue(data); err == nil {
+			st.value[strings.TrimPrefix(string(name), valuePrefix)] = value
+		}
+	}
+	return st
+}
+
+// Exist check if the session of the sid exists
+func (prov *provider) Exist(sid string) bool {
+	reply, err := prov.pool.do("EXISTS", prov.key(sid))
+	return err == nil && reply == int64(1)
+}
+
+// Regenerate rename the session hash to the new sid, or create the new session if the old session does not exist
+func (prov *provider) Regenerate(oldSid, sid string) (session.Storage, error) {
+	reply, err := prov.pool.do("RENAME", prov.key(oldSid), prov.key(sid))
+	// the new session is created if the old session does not exist, the other errors are returned
+	if e, ok := err.(redisError); err != nil && (!ok || !strings.HasPrefix(string(e), "ERR no such key")) {
+		return nil, err
+	}
+	if reply == "OK" {
+		if _, err = prov.pool.do("EXPIRE", prov.key(sid), prov.maxLifetime); err != nil {
+			return nil, err
+		}
+	}
+	return prov.Read(sid), nil
+}
+
+// Destroy delete the session hash by sid
+func (prov *provider) Destroy(sid string) error {
+	_, err := prov.pool.do("DEL", prov.key(sid))
+	return err
+}
+
+// All get the number of the sessions by scanning the session keys
+func (prov *provider) All() int {
+	count := 0
+	cursor := "0"
+	for {
+		reply, err := prov.pool.do("SCAN", cursor, "MATCH", prov.key("*"), "COUNT", 1000)
+		if err != nil {
+			return count
+		}
+		items, _ := reply.([]interface{})
+		if len(items) != 2 {
+			return count
+		}
+		next, _ := items[0].([]byte)
+		keys, _ := items[1].([]interface{})
+		count += len(keys)
+		cursor = string(next)
+		if cursor == "0" || len(cursor) == 0 {
+			return count
+		}
+	}
+}
+
+// Update extend the TTL of the session hash, or create the empty session if it does not exist
+func (prov *provider) Update(sid string) error {
+	k := prov.key(sid)
+	reply, err := prov.pool.do("EXPIRE", k, prov.maxLifetime)
+	if err != nil || reply == int64(1) {
+		return err
+	}
+	_, err = prov.pool.pipeline(prov.createCommand(k), []interface{}{"EXPIRE", k, prov.maxLifetime})
+	return err
+}
+
+// GC the expired sessions are removed by the redis server
+func (prov *provider) GC() {
+}
+
+// NewProvider create the redis session provider. The config can be nil
+func NewProvider(config *Config) session.Provider {
+	prov := &provider{}
+	if config != nil {
+		prov.config = *config
+	}
+	assert.Assert("config.DB", func() bool {
+		return prov.config.DB >= 0
+	})
+	prov.pool = newPool(&prov.config)
+	return prov
+}
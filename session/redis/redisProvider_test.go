@@ -0,0 +1,408 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer the in-process stand-in of the redis server. It understands the commands of the provider on the
+// hashes, and the TTL of the keys is recorded in seconds without expiring the keys
+type fakeServer struct {
+	ln     net.Listener
+	lock   sync.Mutex
+	hashes map[string]map[string][]byte
+	ttl    map[string]int64
+	// errs the error replies of the failed commands
+	errs map[string]string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{ln: ln, hashes: make(map[string]map[string][]byte), ttl: make(map[string]int64), errs: make(map[string]string)}
+	t.Cleanup(func() {
+		ln.Close()
+	})
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+// serve read the commands of the connection, the commands are parsed by the reply reader of the client
+func (s *fakeServer) serve(c net.Conn) {
+	defer c.Close()
+	cn := &conn{c: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)}
+	for {
+		reply, err := cn.readReply()
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]interface{})
+		var args []string
+		for _, item := range items {
+			b, _ := item.([]byte)
+			args = append(args, string(b))
+		}
+		if len(args) == 0 {
+			return
+		}
+		s.lock.Lock()
+		s.exec(cn.w, strings.ToUpper(args[0]), args[1:])
+		s.lock.Unlock()
+		if cn.w.Flush() != nil {
+			return
+		}
+	}
+}
+
+func writeBulk(w *bufio.Writer, b string) {
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(b), b)
+}
+
+func writeInt(w *bufio.Writer, n int) {
+	fmt.Fprintf(w, ":%d\r\n", n)
+}
+
+// exec execute the command and write the reply, the caller holds the lock
+func (s *fakeServer) exec(w *bufio.Writer, cmd string, args []string) {
+	if e, ok := s.errs[cmd]; ok {
+		w.WriteString("-" + e + "\r\n")
+		return
+	}
+	switch cmd {
+	case "PING":
+		w.WriteString("+PONG\r\n")
+	case "HSET":
+		h := s.hashes[args[0]]
+		if h == nil {
+			h = make(map[string][]byte)
+			s.hashes[args[0]] = h
+		}
+		n := 0
+		for i := 1; i+1 < len(args); i += 2 {
+			if _, ok := h[args[i]]; !ok {
+				n++
+			}
+			h[args[i]] = []byte(args[i+1])
+		}
+		writeInt(w, n)
+	case "HGETALL":
+		h := s.hashes[args[0]]
+		var names []string
+		for name := range h {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(w, "*%d\r\n", len(names)*2)
+		for _, name := range names {
+			writeBulk(w, name)
+			writeBulk(w, string(h[name]))
+		}
+	case "HDEL":
+		n := 0
+		if h, ok := s.hashes[args[0]]; ok {
+			for _, name := range args[1:] {
+				if _, ok := h[name]; ok {
+					delete(h, name)
+					n++
+				}
+			}
+			if len(h) == 0 {
+				s.del(args[0])
+			}
+		}
+		writeInt(w, n)
+	case "EXPIRE":
+		if _, ok := s.hashes[args[0]]; !ok {
+			writeInt(w, 0)
+			return
+		}
+		s.ttl[args[0]], _ = strconv.ParseInt(args[1], 10, 64)
+		writeInt(w, 1)
+	case "EXISTS":
+		n := 0
+		for _, key := range args {
+			if _, ok := s.hashes[key]; ok {
+				n++
+			}
+		}
+		writeInt(w, n)
+	case "RENAME":
+		h, ok := s.hashes[args[0]]
+		if !ok {
+			w.WriteString("-ERR no such key\r\n")
+			return
+		}
+		ttl, hasTTL := s.ttl[args[0]]
+		s.del(args[0])
+		s.del(args[1])
+		s.hashes[args[1]] = h
+		if hasTTL {
+			s.ttl[args[1]] = ttl
+		}
+		w.WriteString("+OK\r\n")
+	case "DEL":
+		n := 0
+		for _, key := range args {
+			if _, ok := s.hashes[key]; ok {
+				s.del(key)
+				n++
+			}
+		}
+		writeInt(w, n)
+	case "SCAN":
+		// all the keys are returned by the first call
+		pattern := "*"
+		for i := 1; i+1 < len(args); i += 2 {
+			if strings.ToUpper(args[i]) == "MATCH" {
+				pattern = args[i+1]
+			}
+		}
+		var keys []string
+		for key := range s.hashes {
+			if matched, _ := path.Match(pattern, key); matched {
+				keys = append(keys, key)
+			}
+		}
+		fmt.Fprintf(w, "*2\r\n")
+		writeBulk(w, "0")
+		fmt.Fprintf(w, "*%d\r\n", len(keys))
+		for _, key := range keys {
+			writeBulk(w, key)
+		}
+	default:
+		fmt.Fprintf(w, "-ERR unknown command '%s'\r\n", cmd)
+	}
+}
+
+func (s *fakeServer) del(key string) {
+	delete(s.hashes, key)
+	delete(s.ttl, key)
+}
+
+// keyTTL get the TTL of the key that is set by EXPIRE
+func (s *fakeServer) keyTTL(key string) int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.ttl[key]
+}
+
+// setTTL change the TTL of the key, for example to simulate the time that is passed
+func (s *fakeServer) setTTL(key string, ttl int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.ttl[key] = ttl
+}
+
+// fail make the command reply the error
+func (s *fakeServer) fail(cmd, e string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.errs[cmd] = e
+}
+
+func (s *fakeServer) exists(key string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.hashes[key]
+	return ok
+}
+
+// newTestProvider create the provider that is connected to the new stand-in server
+func newTestProvider(t *testing.T) (*provider, *fakeServer) {
+	s := newFakeServer(t)
+	prov := NewProvider(&Config{Addr: s.ln.Addr().String()}).(*provider)
+	if err := prov.Init(3600, ""); err != nil {
+		t.Fatal(err)
+	}
+	return prov, s
+}
+
+func TestRead(t *testing.T) {
+	prov, s := newTestProvider(t)
+	st := prov.Read("s1")
+	if len(st.(*storage).value) != 0 {
+		t.Fatal("the new session is not empty")
+	}
+	if !s.exists("session:s1") || !prov.Exist("s1") {
+		t.Fatal("the new session is not created")
+	}
+	if ttl := s.keyTTL("session:s1"); ttl != 3600 {
+		t.Fatalf("unexpected TTL %d", ttl)
+	}
+	if prov.Exist("s2") {
+		t.Fatal("the unknown session exists")
+	}
+}
+
+func TestSet(t *testing.T) {
+	prov, _ := newTestProvider(t)
+	st := prov.Read("s1")
+	if err := st.Set("name", "mego"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Set("count", 3); err != nil {
+		t.Fatal(err)
+	}
+	st = prov.Read("s1")
+	if v := st.Get("name"); v != "mego" {
+		t.Fatalf("unexpected value %v", v)
+	}
+	if v := st.Get("count"); v != 3 {
+		t.Fatalf("unexpected value %v", v)
+	}
+	if err := st.Delete("name"); err != nil {
+		t.Fatal(err)
+	}
+	if v := prov.Read("s1").Get("name"); v != nil {
+		t.Fatalf("the deleted value is read: %v", v)
+	}
+	if err := st.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if v := prov.Read("s1").Get("count"); v != nil {
+		t.Fatalf("the flushed value is read: %v", v)
+	}
+	if n := prov.All(); n != 1 {
+		t.Fatalf("unexpected number of the sessions %d", n)
+	}
+}
+
+func TestRegenerate(t *testing.T) {
+	prov, s := newTestProvider(t)
+	if err := prov.Read("old").Set("name", "mego"); err != nil {
+		t.Fatal(err)
+	}
+	st, err := prov.Regenerate("old", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.ID() != "new" || st.Get("name") != "mego" {
+		t.Fatalf("the session values are not kept: %s %v", st.ID(), st.Get("name"))
+	}
+	if s.exists("session:old") {
+		t.Fatal("the old session is not renamed")
+	}
+	// the session that does not exist is created
+	st, err = prov.Regenerate("missing", "created")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Get("name") != nil || !s.exists("session:created") {
+		t.Fatal("the new session is not created")
+	}
+	// the other errors are not taken as the missing session
+	s.fail("RENAME", "WRONGTYPE Operation against a key holding the wrong kind of value")
+	if _, err = prov.Regenerate("created", "next"); err == nil {
+		t.Fatal("the error of RENAME is ignored")
+	}
+	if s.exists("session:next") {
+		t.Fatal("the new session is created")
+	}
+}
+
+func TestDestroy(t *testing.T) {
+	prov, s := newTestProvider(t)
+	if err := prov.Read("s1").Set("name", "mego"); err != nil {
+		t.Fatal(err)
+	}
+	if err := prov.Destroy("s1"); err != nil {
+		t.Fatal(err)
+	}
+	if s.exists("session:s1") || prov.Exist("s1") {
+		t.Fatal("the session is not destroyed")
+	}
+	if v := prov.Read("s1").Get("name"); v != nil {
+		t.Fatalf("the destroyed value is read: %v", v)
+	}
+}
+
+func TestRefreshTTL(t *testing.T) {
+	prov, s := newTestProvider(t)
+	st := prov.Read("s1")
+	s.setTTL("session:s1", 1)
+	prov.Read("s1")
+	if ttl := s.keyTTL("session:s1"); ttl != 3600 {
+		t.Fatalf("the TTL is not refreshed by Read: %d", ttl)
+	}
+	s.setTTL("session:s1", 1)
+	if err := st.Set("name", "mego"); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := s.keyTTL("session:s1"); ttl != 3600 {
+		t.Fatalf("the TTL is not refreshed by Set: %d", ttl)
+	}
+	s.setTTL("session:s1", 1)
+	if err := prov.Update("s1"); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := s.keyTTL("session:s1"); ttl != 3600 {
+		t.Fatalf("the TTL is not refreshed by Update: %d", ttl)
+	}
+	s.setTTL("session:s1", 1)
+	if _, err := prov.Regenerate("s1", "s2"); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := s.keyTTL("session:s2"); ttl != 3600 {
+		t.Fatalf("the TTL is not refreshed by Regenerate: %d", ttl)
+	}
+}
+
+func TestBrokenCommand(t *testing.T) {
+	prov, _ := newTestProvider(t)
+	// the half-written command is not sent by the next pipeline of the pooled connection
+	if _, err := prov.pool.do("EXISTS", struct{}{}); err == nil {
+		t.Fatal("the unsupported argument is sent")
+	}
+	if reply, err := prov.pool.do("PING"); err != nil || reply != "PONG" {
+		t.Fatalf("unexpected reply %v %v", reply, err)
+	}
+	if err := prov.Read("s1").Set("name", "mego"); err != nil {
+		t.Fatal(err)
+	}
+	if v := prov.Read("s1").Get("name"); v != "mego" {
+		t.Fatalf("unexpected value %v", v)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// the stalled server accepts the connection without replying
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		time.Sleep(2 * time.Second)
+	}()
+	prov := NewProvider(&Config{Addr: ln.Addr().String(), Timeout: 100 * time.Millisecond}).(*provider)
+	start := time.Now()
+	if err := prov.Init(3600, ""); err == nil {
+		t.Fatal("the stalled server replies")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("the command is not timed out: %v", d)
+	}
+}
@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"net/http"
+	"sync"
+)
+
+// storage redis session store.
+// it keeps the session values in the redis hash and caches them in memory.
+type storage struct {
+	prov  *provider
+	sid   string
+	value map[string]interface{}
+	lock  sync.RWMutex
+}
+
+// Set Value to redis session
+func (st *storage) Set(key string, value interface{}) error {
+	data, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	k := st.prov.key(st.sid)
+	_, err = st.prov.pool.pipeline(
+		[]interface{}{"HSET", k, valuePrefix + key, data},
+		[]interface{}{"EXPIRE", k, st.prov.maxLifetime},
+	)
+	if err != nil {
+		return err
+	}
+	st.value[key] = value
+	return nil
+}
+
+// Get Value from redis session by key
+func (st *storage) Get(key string) interface{} {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	if v, ok := st.value[key]; ok {
+		return v
+	}
+	return nil
+}
+
+// Delete in redis session by key
+func (st *storage) Delete(key string) error {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if _, err := st.prov.pool.do("HDEL", st.prov.key(st.sid), valuePrefix+key); err != nil {
+		return err
+	}
+	delete(st.value, key)
+	return nil
+}
+
+// Flush clear all values in redis session
+func (st *storage) Flush() error {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	k := st.prov.key(st.sid)
+	_, err := st.prov.pool.pipeline(
+		[]interface{}{"DEL", k},
+		st.prov.createCommand(k),
+		[]interface{}{"EXPIRE", k, st.prov.maxLifetime},
+	)
+	if err != nil {
+		return err
+	}
+	st.value = make(map[string]interface{})
+	return nil
+}
+
+// ID get this id of redis session store
+func (st *storage) ID() string {
+	return st.sid
+}
+
+// Release Implement method, the values are saved when they are changed
+func (st *storage) Release(w http.ResponseWriter) {
+}
@@ -0,0 +1,228 @@
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// redisError the error reply of the redis server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// conn the connection of the RESP protocol
+type conn struct {
+	c      net.Conn
+	r      *bufio.Reader
+	w      *bufio.Writer
+	broken bool
+	// timeout the read and write timeout of the commands
+	timeout time.Duration
+}
+
+// writeCommand write the command as the array of the bulk strings
+func (cn *conn) writeCommand(args []interface{}) error {
+	fmt.Fprintf(cn.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var b []byte
+		switch v := arg.(type) {
+		case []byte:
+			b = v
+		case string:
+			b = []byte(v)
+		case int:
+			b = strconv.AppendInt(nil, int64(v), 10)
+		case int64:
+			b = strconv.AppendInt(nil, v, 10)
+		default:
+			return fmt.Errorf("redis: unsupported argument type %T", arg)
+		}
+		fmt.Fprintf(cn.w, "$%d\r\n", len(b))
+		cn.w.Write(b)
+		cn.w.WriteString("\r\n")
+	}
+	return nil
+}
+
+func (cn *conn) readLine() (string, error) {
+	line, err := cn.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", errors.New("redis: invalid reply line")
+	}
+	return line[:len(line)-2], nil
+}
+
+// readReply read the reply: string, redisError, int64, []byte, []interface{} or nil
+func (cn *conn) readReply() (interface{}, error) {
+	line, err := cn.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return redisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err = io.ReadFull(cn.r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = cn.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: invalid reply type %q", line[0])
+}
+
+// pool the pool of the redis connections
+type pool struct {
+	config *Config
+	idle   chan *conn
+}
+
+func newPool(config *Config) *pool {
+	size := config.PoolSize
+	if size <= 0 {
+		size = 10
+	}
+	return &pool{config: config, idle: make(chan *conn, size)}
+}
+
+// dial create the connection, and authenticate and select the database if they are configured
+func (p *pool) dial() (*conn, error) {
+	addr := p.config.Addr
+	if len(addr) == 0 {
+		addr = "127.0.0.1:6379"
+	}
+	timeout := p.config.DialTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	c, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{c: c, r: bufio.NewReader(c), w: bufio.NewWriter(c), timeout: p.config.Timeout}
+	if cn.timeout <= 0 {
+		cn.timeout = 5 * time.Second
+	}
+	var setup [][]interface{}
+	if len(p.config.Password) > 0 {
+		setup = append(setup, []interface{}{"AUTH", p.config.Password})
+	}
+	if p.config.DB > 0 {
+		setup = append(setup, []interface{}{"SELECT", p.config.DB})
+	}
+	if len(setup) > 0 {
+		if _, err = cn.pipeline(setup); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (p *pool) get() (*conn, error) {
+	select {
+	case cn := <-p.idle:
+		return cn, nil
+	default:
+		return p.dial()
+	}
+}
+
+func (p *pool) put(cn *conn) {
+	if cn.broken {
+		cn.c.Close()
+		return
+	}
+	select {
+	case p.idle <- cn:
+	default:
+		cn.c.Close()
+	}
+}
+
+// pipeline send the commands and read the replies. It returns the first error reply as the error. The connection
+// is broken by any write or read error, so the half-written commands and the unread replies are not left for the
+// next pipeline
+func (cn *conn) pipeline(cmds [][]interface{}) ([]interface{}, error) {
+	// the stalled server does not block the request
+	if err := cn.c.SetDeadline(time.Now().Add(cn.timeout)); err != nil {
+		cn.broken = true
+		return nil, err
+	}
+	for _, cmd := range cmds {
+		if err := cn.writeCommand(cmd); err != nil {
+			cn.broken = true
+			return nil, err
+		}
+	}
+	if err := cn.w.Flush(); err != nil {
+		cn.broken = true
+		return nil, err
+	}
+	replies := make([]interface{}, len(cmds))
+	var replyErr error
+	for i := range cmds {
+		reply, err := cn.readReply()
+		if err != nil {
+			cn.broken = true
+			return nil, err
+		}
+		if e, ok := reply.(redisError); ok && replyErr == nil {
+			replyErr = e
+		}
+		replies[i] = reply
+	}
+	return replies, replyErr
+}
+
+// pipeline send the commands by one pooled connection
+func (p *pool) pipeline(cmds ...[]interface{}) ([]interface{}, error) {
+	cn, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	defer p.put(cn)
+	return cn.pipeline(cmds)
+}
+
+// do send the command by the pooled connection and get the reply
+func (p *pool) do(args ...interface{}) (interface{}, error) {
+	replies, err := p.pipeline(args)
+	if err != nil {
+		return nil, err
+	}
+	return replies[0], nil
+}
@@ -2,15 +2,18 @@ package session
 
 import (
 	"errors"
+	"github.com/simbory/mego"
 	"github.com/simbory/mego/assert"
 )
 
 var defaultManager *Manager
 
-// UseAsDefault use the given session manager as the default
+// UseAsDefault use the given session manager as the default. The flash messages are stored in the sessions of the
+// default manager unless the server has its own flash store
 func UseAsDefault(manager *Manager) {
 	assert.NotNil("manager", manager)
 	defaultManager = manager
+	mego.UseDefaultFlashStore(NewFlashStore(manager))
 }
 
 func CreateManager(config *Config, provider Provider) *Manager {
@@ -32,19 +35,31 @@ func CreateManager(config *Config, provider Provider) *Manager {
 		config.CookiePath = "/"
 	}
 
+	if config.IDLength <= 0 {
+		config.IDLength = defaultIDLength
+	}
 	if config.MaxLifetime < config.GcLifetime {
 		config.MaxLifetime = config.GcLifetime
 	}
 	config.EnableSetCookie = true
+	// the pages of the sessions are not served from the output cache
+	mego.RegisterPrivateCookie(config.CookieName)
 
 	m := &Manager{
 		provider:  provider,
 		config:    config,
-		managerID: newGuidStr(),
+		managerID: managerID(),
 	}
 	return m
 }
 
+// managerID generate the random id of the manager, it's the key of the session storage in the http context
+func managerID() string {
+	id, err := RandomID(16)
+	assert.PanicErr(err)
+	return id
+}
+
 func Default() *Manager {
 	if defaultManager == nil {
 		panic(errors.New("You need to call UseDefault() first when you get the default session manager"))
@@ -0,0 +1,258 @@
+// Package sqlstore implements the session provider that keeps the sessions in the relational database by
+// database/sql. The sessions are saved in the table with the columns 'id', 'data' and 'expires', and the session
+// values are encoded by gob (see session.RegisterType).
+package sqlstore
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/gob"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/simbory/mego/assert"
+	"github.com/simbory/mego/session"
+)
+
+// Config the config of the sql session provider
+type Config struct {
+	// Table the name of the session table, the default value is 'sessions'
+	Table string
+	// Placeholder the placeholder style of the database driver: '?' (the default value, for example MySQL and
+	// SQLite) or '$' for the numbered placeholders '$1', '$2' ... (for example PostgreSQL)
+	Placeholder string
+	// MaxLifetime the lifetime of the session in seconds after it's accessed, the default value is the gc
+	// lifetime of the session manager
+	MaxLifetime int64
+}
+
+func (c *Config) table() string {
+	if len(c.Table) == 0 {
+		return "sessions"
+	}
+	return c.Table
+}
+
+// Schema get the statements that create the session table and the index of the expiration time
+func Schema(config *Config) []string {
+	assert.NotNil("config", config)
+	assert.Assert("config.Table", func() bool {
+		return tableReg.MatchString(config.table())
+	})
+	dataType := "BLOB"
+	if config.Placeholder == "$" {
+		dataType = "BYTEA"
+	}
+	table := config.table()
+	return []string{
+		"CREATE TABLE IF NOT EXISTS " + table + " (id VARCHAR(128) NOT NULL PRIMARY KEY, data " + dataType + ", expires BIGINT NOT NULL)",
+		"CREATE INDEX IF NOT EXISTS " + table + "_expires ON " + table + " (expires)",
+	}
+}
+
+// CreateSchema create the session table and the index if they do not exist. The statements work on SQLite and
+// PostgreSQL, and the table of the other databases can be created by the statements of Schema with the changes
+// of the dialect
+func CreateSchema(db *sql.DB, config *Config) error {
+	assert.NotNil("db", db)
+	for _, stmt := range Schema(config) {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var tableReg = regexp.MustCompile(`^[A-Za-z_][\w.]*$`)
+
+// provider Implement the provider interface
+type provider struct {
+	db          *sql.DB
+	config      Config
+	maxLifetime int64
+}
+
+// query replace the '?' placeholders of the query with the placeholders of the driver
+func (prov *provider) query(q string) string {
+	q = strings.Replace(q, "{table}", prov.config.table(), -1)
+	if prov.config.Placeholder != "$" {
+		return q
+	}
+	buf := &strings.Builder{}
+	n := 0
+	for _, c := range q {
+		if c == '?' {
+			n++
+			buf.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		buf.WriteRune(c)
+	}
+	return buf.String()
+}
+
+func (prov *provider) expires() int64 {
+	return time.Now().Unix() + prov.maxLifetime
+}
+
+func encode(value map[string]interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := gob.NewEncoder(buf).Encode(value)
+	return buf.Bytes(), err
+}
+
+func decode(data []byte) (map[string]interface{}, error) {
+	value := make(map[string]interface{})
+	if len(data) == 0 {
+		return value, nil
+	}
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, err
+}
+
+// upsert update the session data and the expiration time, or insert the session if it does not exist.
+// The session is inserted by the concurrent request if the insert fails, so it's updated again
+func (prov *provider) upsert(sid string, data []byte) error {
+	for i := 0; ; i++ {
+		res, err := prov.db.Exec(prov.query("UPDATE {table} SET data = ?, expires = ? WHERE id = ?"), data, prov.expires(), sid)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			return nil
+		}
+		_, err = prov.db.Exec(prov.query("INSERT INTO {table} (id, data, expires) VALUES (?, ?, ?)"), sid, data, prov.expires())
+		if err == nil || i > 0 {
+			return err
+		}
+	}
+}
+
+// Init init sql session
+func (prov *provider) Init(gcLifetime int64, config string) error {
+	prov.maxLifetime = prov.config.MaxLifetime
+	if prov.maxLifetime <= 0 {
+		prov.maxLifetime = gcLifetime
+	}
+	return prov.db.Ping()
+}
+
+// Read get the session store by sid, the new session is inserted if it does not exist or it's expired
+func (prov *provider) Read(sid string) session.Storage {
+	var data []byte
+	var expires int64
+	err := prov.db.QueryRow(prov.query("SELECT data, expires FROM {table} WHERE id = ?"), sid).Scan(&data, &expires)
+	if err != nil && err != sql.ErrNoRows {
+		assert.PanicErr(err)
+	}
+	st := &storage{prov: prov, sid: sid, value: make(map[string]interface{})}
+	if err == nil && expires > time.Now().Unix() {
+		if value, err := decode(data); err == nil {
+			st.value = value
+		}
+		assert.PanicErr(prov.Update(sid))
+		return st
+	}
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	assert.PanicErr(st.save())
+	return st
+}
+
+// Exist check if the session of the sid exists and it's not expired
+func (prov *provider) Exist(sid string) bool {
+	var n int
+	err := prov.db.QueryRow(prov.query("SELECT COUNT(*) FROM {table} WHERE id = ? AND expires > ?"), sid, time.Now().Unix()).Scan(&n)
+	return err == nil && n > 0
+}
+
+// Regenerate change the session id in the transaction, or create the new session if the old session does not exist.
+// The row is moved by a single UPDATE, so the concurrent changes of the old session are not lost between the read
+// and the write
+func (prov *provider) Regenerate(oldSid, sid string) (session.Storage, error) {
+	tx, err := prov.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec(prov.query("DELETE FROM {table} WHERE id = ?"), sid); err != nil {
+		return nil, err
+	}
+	res, err := tx.Exec(prov.query("UPDATE {table} SET id = ?, expires = ? WHERE id = ? AND expires > ?"), sid, prov.expires(), oldSid, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		if err = tx.QueryRow(prov.query("SELECT data FROM {table} WHERE id = ?"), sid).Scan(&data); err != nil {
+			return nil, err
+		}
+	} else {
+		// the old session does not exist or it's expired
+		if _, err = tx.Exec(prov.query("DELETE FROM {table} WHERE id = ?"), oldSid); err != nil {
+			return nil, err
+		}
+		if _, err = tx.Exec(prov.query("INSERT INTO {table} (id, data, expires) VALUES (?, ?, ?)"), sid, data, prov.expires()); err != nil {
+			return nil, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	value, err := decode(data)
+	if err != nil {
+		value = make(map[string]interface{})
+	}
+	return &storage{prov: prov, sid: sid, value: value}, nil
+}
+
+// Destroy delete the session by sid
+func (prov *provider) Destroy(sid string) error {
+	_, err := prov.db.Exec(prov.query("DELETE FROM {table} WHERE id = ?"), sid)
+	return err
+}
+
+// All get the number of the active sessions
+func (prov *provider) All() int {
+	var n int
+	if err := prov.db.QueryRow(prov.query("SELECT COUNT(*) FROM {table} WHERE expires > ?"), time.Now().Unix()).Scan(&n); err != nil {
+		return 0
+	}
+	return n
+}
+
+// Update extend the expiration time of the session, or insert the empty session if it does not exist
+func (prov *provider) Update(sid string) error {
+	res, err := prov.db.Exec(prov.query("UPDATE {table} SET expires = ? WHERE id = ?"), prov.expires(), sid)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		return nil
+	}
+	data, err := encode(make(map[string]interface{}))
+	if err != nil {
+		return err
+	}
+	return prov.upsert(sid, data)
+}
+
+// GC delete the expired sessions by the index of the expiration time
+func (prov *provider) GC() {
+	prov.db.Exec(prov.query("DELETE FROM {table} WHERE expires <= ?"), time.Now().Unix())
+}
+
+// NewProvider create the sql session provider of the database. The config can be nil
+func NewProvider(db *sql.DB, config *Config) session.Provider {
+	assert.NotNil("db", db)
+	prov := &provider{db: db}
+	if config != nil {
+		prov.config = *config
+	}
+	assert.Assert("config.Table", func() bool {
+		return tableReg.MatchString(prov.config.table())
+	})
+	return prov
+}
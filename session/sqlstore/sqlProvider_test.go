@@ -0,0 +1,378 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRow the row of the fake session table
+type fakeRow struct {
+	data    []byte
+	expires int64
+}
+
+// fakeDB the in-process database of the fake driver. It only understands the statements of the provider on the
+// table 'sessions', and the transaction holds the database until it's committed or rolled back
+type fakeDB struct {
+	lock sync.Mutex
+	tx   sync.Mutex
+	rows map[string]fakeRow
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{db: db}, nil
+}
+
+func (db *fakeDB) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fake driver: use the connector")
+}
+
+// fakeConn the connection of the fake database
+type fakeConn struct {
+	db     *fakeDB
+	inTx   bool
+	backup map[string]fakeRow
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.tx.Lock()
+	c.db.lock.Lock()
+	c.backup = make(map[string]fakeRow)
+	for id, row := range c.db.rows {
+		c.backup[id] = row
+	}
+	c.db.lock.Unlock()
+	c.inTx = true
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.inTx = false
+	c.db.tx.Unlock()
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.db.lock.Lock()
+	c.db.rows = c.backup
+	c.db.lock.Unlock()
+	c.inTx = false
+	c.db.tx.Unlock()
+	return nil
+}
+
+// fakeStmt the statement of the fake database
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (st *fakeStmt) Close() error {
+	return nil
+}
+
+func (st *fakeStmt) NumInput() int {
+	return -1
+}
+
+// run execute the statement, the statements out of the transaction wait for the running transaction
+func (st *fakeStmt) run(args []driver.Value) (int64, [][]driver.Value, error) {
+	if !st.conn.inTx {
+		st.conn.db.tx.Lock()
+		defer st.conn.db.tx.Unlock()
+	}
+	db := st.conn.db
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	bytesArg := func(v driver.Value) []byte {
+		b, _ := v.([]byte)
+		return b
+	}
+	switch st.query {
+	case "SELECT data, expires FROM sessions WHERE id = ?":
+		if row, ok := db.rows[args[0].(string)]; ok {
+			return 0, [][]driver.Value{{row.data, row.expires}}, nil
+		}
+		return 0, nil, nil
+	case "SELECT data FROM sessions WHERE id = ?":
+		if row, ok := db.rows[args[0].(string)]; ok {
+			return 0, [][]driver.Value{{row.data}}, nil
+		}
+		return 0, nil, nil
+	case "SELECT COUNT(*) FROM sessions WHERE id = ? AND expires > ?":
+		var n int64
+		if row, ok := db.rows[args[0].(string)]; ok && row.expires > args[1].(int64) {
+			n = 1
+		}
+		return 0, [][]driver.Value{{n}}, nil
+	case "SELECT COUNT(*) FROM sessions WHERE expires > ?":
+		var n int64
+		for _, row := range db.rows {
+			if row.expires > args[0].(int64) {
+				n++
+			}
+		}
+		return 0, [][]driver.Value{{n}}, nil
+	case "INSERT INTO sessions (id, data, expires) VALUES (?, ?, ?)":
+		id := args[0].(string)
+		if _, ok := db.rows[id]; ok {
+			return 0, nil, fmt.Errorf("fake driver: duplicate id %s", id)
+		}
+		db.rows[id] = fakeRow{data: bytesArg(args[1]), expires: args[2].(int64)}
+		return 1, nil, nil
+	case "UPDATE sessions SET data = ?, expires = ? WHERE id = ?":
+		id := args[2].(string)
+		if _, ok := db.rows[id]; !ok {
+			return 0, nil, nil
+		}
+		db.rows[id] = fakeRow{data: bytesArg(args[0]), expires: args[1].(int64)}
+		return 1, nil, nil
+	case "UPDATE sessions SET expires = ? WHERE id = ?":
+		id := args[1].(string)
+		row, ok := db.rows[id]
+		if !ok {
+			return 0, nil, nil
+		}
+		row.expires = args[0].(int64)
+		db.rows[id] = row
+		return 1, nil, nil
+	case "UPDATE sessions SET id = ?, expires = ? WHERE id = ? AND expires > ?":
+		id, oldID := args[0].(string), args[2].(string)
+		row, ok := db.rows[oldID]
+		if !ok || row.expires <= args[3].(int64) {
+			return 0, nil, nil
+		}
+		if _, ok := db.rows[id]; ok {
+			return 0, nil, fmt.Errorf("fake driver: duplicate id %s", id)
+		}
+		delete(db.rows, oldID)
+		row.expires = args[1].(int64)
+		db.rows[id] = row
+		return 1, nil, nil
+	case "DELETE FROM sessions WHERE id = ?":
+		id := args[0].(string)
+		if _, ok := db.rows[id]; !ok {
+			return 0, nil, nil
+		}
+		delete(db.rows, id)
+		return 1, nil, nil
+	case "DELETE FROM sessions WHERE expires <= ?":
+		var n int64
+		for id, row := range db.rows {
+			if row.expires <= args[0].(int64) {
+				delete(db.rows, id)
+				n++
+			}
+		}
+		return n, nil, nil
+	}
+	return 0, nil, fmt.Errorf("fake driver: unsupported statement %q", st.query)
+}
+
+func (st *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	n, _, err := st.run(args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(n), nil
+}
+
+func (st *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	_, rows, err := st.run(args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{rows: rows}, nil
+}
+
+// fakeRows the result rows of the query
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return []string{"data"}
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// newTestProvider create the provider of the new fake database
+func newTestProvider(t *testing.T) (*provider, *fakeDB) {
+	fake := &fakeDB{rows: make(map[string]fakeRow)}
+	db := sql.OpenDB(fake)
+	t.Cleanup(func() {
+		db.Close()
+	})
+	prov := NewProvider(db, nil).(*provider)
+	if err := prov.Init(3600, ""); err != nil {
+		t.Fatal(err)
+	}
+	return prov, fake
+}
+
+// expire make the session of the fake database expired
+func (db *fakeDB) expire(id string) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	row := db.rows[id]
+	row.expires = time.Now().Unix() - 1
+	db.rows[id] = row
+}
+
+func TestQuery(t *testing.T) {
+	prov := &provider{config: Config{Table: "app_sessions", Placeholder: "$"}}
+	q := prov.query("UPDATE {table} SET data = ?, expires = ? WHERE id = ?")
+	if q != "UPDATE app_sessions SET data = $1, expires = $2 WHERE id = $3" {
+		t.Fatalf("unexpected query %q", q)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	prov, fake := newTestProvider(t)
+	st := prov.Read("s1")
+	if len(fake.rows) != 1 {
+		t.Fatalf("the new session is not inserted: %d rows", len(fake.rows))
+	}
+	if err := st.Set("name", "mego"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Set("name", "session"); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.rows) != 1 {
+		t.Fatalf("the session is inserted twice: %d rows", len(fake.rows))
+	}
+	if !prov.Exist("s1") {
+		t.Fatal("the session does not exist")
+	}
+	if v := prov.Read("s1").Get("name"); v != "session" {
+		t.Fatalf("unexpected value %v", v)
+	}
+	// the session that is deleted by another request is inserted again
+	prov.Destroy("s1")
+	if err := st.Set("name", "again"); err != nil {
+		t.Fatal(err)
+	}
+	if v := prov.Read("s1").Get("name"); v != "again" {
+		t.Fatalf("unexpected value %v", v)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	prov, fake := newTestProvider(t)
+	if err := prov.Read("s1").Set("name", "mego"); err != nil {
+		t.Fatal(err)
+	}
+	fake.expire("s1")
+	if prov.Exist("s1") {
+		t.Fatal("the expired session exists")
+	}
+	st := prov.Read("s1")
+	if v := st.Get("name"); v != nil {
+		t.Fatalf("the expired session is read: %v", v)
+	}
+	if !prov.Exist("s1") {
+		t.Fatal("the expired session is not renewed")
+	}
+	if err := prov.Update("s2"); err != nil {
+		t.Fatal(err)
+	}
+	if !prov.Exist("s2") {
+		t.Fatal("the updated session is not inserted")
+	}
+}
+
+func TestGC(t *testing.T) {
+	prov, fake := newTestProvider(t)
+	prov.Read("s1")
+	prov.Read("s2")
+	prov.Read("s3")
+	fake.expire("s2")
+	if n := prov.All(); n != 2 {
+		t.Fatalf("unexpected number of the active sessions %d", n)
+	}
+	prov.GC()
+	if len(fake.rows) != 2 {
+		t.Fatalf("unexpected number of the rows %d", len(fake.rows))
+	}
+	if _, ok := fake.rows["s2"]; ok {
+		t.Fatal("the expired session is not deleted")
+	}
+}
+
+func TestRegenerate(t *testing.T) {
+	prov, fake := newTestProvider(t)
+	if err := prov.Read("old").Set("name", "mego"); err != nil {
+		t.Fatal(err)
+	}
+	st, err := prov.Regenerate("old", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.ID() != "new" || st.Get("name") != "mego" {
+		t.Fatalf("the session values are not kept: %s %v", st.ID(), st.Get("name"))
+	}
+	if prov.Exist("old") {
+		t.Fatal("the old session is not deleted")
+	}
+	if v := prov.Read("new").Get("name"); v != "mego" {
+		t.Fatalf("unexpected value %v", v)
+	}
+	// the expired session is not moved
+	fake.expire("new")
+	st, err = prov.Regenerate("new", "newer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Get("name") != nil {
+		t.Fatal("the expired session values are kept")
+	}
+	if _, ok := fake.rows["new"]; ok {
+		t.Fatal("the expired session is not deleted")
+	}
+	if !prov.Exist("newer") {
+		t.Fatal("the new session is not inserted")
+	}
+	// the session that does not exist is created
+	if _, err = prov.Regenerate("missing", "created"); err != nil {
+		t.Fatal(err)
+	}
+	if !prov.Exist("created") {
+		t.Fatal("the new session is not inserted")
+	}
+}
@@ -0,0 +1,67 @@
+package sqlstore
+
+import (
+	"net/http"
+	"sync"
+)
+
+// storage sql session store.
+// it keeps the session values in memory and writes them to the database when they are changed.
+type storage struct {
+	prov  *provider
+	sid   string
+	value map[string]interface{}
+	lock  sync.RWMutex
+}
+
+// save write the session values to the database, the caller must hold the lock
+func (st *storage) save() error {
+	data, err := encode(st.value)
+	if err != nil {
+		return err
+	}
+	return st.prov.upsert(st.sid, data)
+}
+
+// Set Value to sql session
+func (st *storage) Set(key string, value interface{}) error {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.value[key] = value
+	return st.save()
+}
+
+// Get Value from sql session by key
+func (st *storage) Get(key string) interface{} {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	if v, ok := st.value[key]; ok {
+		return v
+	}
+	return nil
+}
+
+// Delete in sql session by key
+func (st *storage) Delete(key string) error {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	delete(st.value, key)
+	return st.save()
+}
+
+// Flush clear all values in sql session
+func (st *storage) Flush() error {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.value = make(map[string]interface{})
+	return st.save()
+}
+
+// ID get this id of sql session store
+func (st *storage) ID() string {
+	return st.sid
+}
+
+// Release Implement method, the values are saved when they are changed
+func (st *storage) Release(w http.ResponseWriter) {
+}
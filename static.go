@@ -0,0 +1,312 @@
+package mego
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/simbory/mego/assert"
+)
+
+// StaticOption the options of the static file serving
+type StaticOption struct {
+	// CacheControl the 'Cache-Control' header of the files by the lower case file extension (for example ".css"),
+	// and the key "*" is used for the other files
+	CacheControl map[string]string
+	// Expires the duration of the 'Expires' header of the files by the lower case file extension, and the key "*"
+	// is used for the other files
+	Expires map[string]time.Duration
+	// IndexFiles the index files that are served for the directory in order, for example 'index.html'
+	IndexFiles []string
+	// DirectoryListing list the files of the directory that has no index file
+	DirectoryListing bool
+	// AllowHidden allow the hidden files and directories whose names start with '.'. They are denied by default
+	AllowHidden bool
+	// Fallback the file that is served for the unmatched paths without file extension, for example the entry point
+	// 'index.html' of the single page application. The fallback file is relative to the static directory
+	Fallback string
+}
+
+// staticMount the static directory mounted on the url prefix
+type staticMount struct {
+	prefix string
+	fsys   fs.FS
+	opt    StaticOption
+}
+
+// name get the file name in the static directory of the url path. It returns false if the url path is not
+// under the url prefix
+func (m *staticMount) name(urlPath string) (string, bool) {
+	if m.prefix != "/" {
+		if urlPath != m.prefix && !strings.HasPrefix(urlPath, m.prefix+"/") {
+			return "", false
+		}
+		urlPath = urlPath[len(m.prefix):]
+	}
+	name := strings.Trim(urlPath, "/")
+	if len(name) == 0 {
+		name = "."
+	}
+	return name, true
+}
+
+// isHidden check if any element of the file name starts with '.'
+func isHidden(name string) bool {
+	for _, elem := range strings.Split(name, "/") {
+		if strings.HasPrefix(elem, ".") && elem != "." {
+			return true
+		}
+	}
+	return false
+}
+
+// headerValue get the option value of the file extension
+func headerValue(name string, values map[string]string) (string, bool) {
+	if v, ok := values[strings.ToLower(path.Ext(name))]; ok {
+		return v, true
+	}
+	v, ok := values["*"]
+	return v, ok
+}
+
+// serve serve the file, the index file or the directory listing. It returns false if the file is not found
+func (m *staticMount) serve(s *Server, w http.ResponseWriter, r *http.Request, name string) bool {
+	if !m.opt.AllowHidden && isHidden(name) {
+		s.err403Handler(w, r)
+		return true
+	}
+	stat, err := fs.Stat(m.fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+			return false
+		}
+		s.err403Handler(w, r)
+		return true
+	}
+	if !stat.IsDir() {
+		return m.serveFile(s, w, r, name)
+	}
+	var index string
+	for _, file := range m.opt.IndexFiles {
+		p := path.Join(name, file)
+		if stat, err := fs.Stat(m.fsys, p); err == nil && !stat.IsDir() {
+			index = p
+			break
+		}
+	}
+	if len(index) == 0 && !m.opt.DirectoryListing {
+		return false
+	}
+	// the relative links in the index file and the directory listing require the trailing slash
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		u := *r.URL
+		u.Path = u.Path + "/"
+		http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+		return true
+	}
+	if len(index) > 0 {
+		return m.serveFile(s, w, r, index)
+	}
+	m.list(s, w, r, name)
+	return true
+}
+
+// serveFile serve the file with the cache headers
+func (m *staticMount) serveFile(s *Server, w http.ResponseWriter, r *http.Request, name string) bool {
+	f, err := m.fsys.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil || stat.IsDir() {
+		return false
+	}
+	if cc, ok := headerValue(name, m.opt.CacheControl); ok {
+		w.Header().Set("Cache-Control", cc)
+	}
+	var expires map[string]string
+	for ext, d := range m.opt.Expires {
+		if expires == nil {
+			expires = make(map[string]string)
+		}
+		expires[ext] = time.Now().Add(d).UTC().Format(http.TimeFormat)
+	}
+	if v, ok := headerValue(name, expires); ok {
+		w.Header().Set("Expires", v)
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			s.err403Handler(w, r)
+			return true
+		}
+		content = bytes.NewReader(data)
+	}
+	http.ServeContent(w, r, stat.Name(), stat.ModTime(), content)
+	return true
+}
+
+// list write the html page that lists the files of the directory
+func (m *staticMount) list(s *Server, w http.ResponseWriter, r *http.Request, name string) {
+	entries, err := fs.ReadDir(m.fsys, name)
+	if err != nil {
+		s.err403Handler(w, r)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	buf := &bytes.Buffer{}
+	title := html.EscapeString(r.URL.Path)
+	fmt.Fprintf(buf, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>Index of %s</title></head><body>\n", title)
+	fmt.Fprintf(buf, "<h3>Index of %s</h3>\n<ul>\n", title)
+	if name != "." {
+		buf.WriteString("<li><a href=\"../\">../</a></li>\n")
+	}
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if !m.opt.AllowHidden && strings.HasPrefix(entryName, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			entryName = entryName + "/"
+		}
+		link := url.URL{Path: entryName}
+		fmt.Fprintf(buf, "<li><a href=\"%s\">%s</a></li>\n", link.String(), html.EscapeString(entryName))
+	}
+	buf.WriteString("</ul>\n</body></html>")
+	result := NewBufResult(buf)
+	result.ContentType = "text/html"
+	result.ExecResult(w, r)
+}
+
+// serveFallback serve the fallback file of the single page application
+func (m *staticMount) serveFallback(s *Server, w http.ResponseWriter, r *http.Request) bool {
+	if len(m.opt.Fallback) == 0 || (r.Method != "GET" && r.Method != "HEAD") {
+		return false
+	}
+	// the entry point of the single page application references the fingerprinted assets, so it's not cached
+	w.Header().Set("Cache-Control", "no-cache")
+	if !m.serveFile(s, w, r, strings.TrimLeft(ClearPath(m.opt.Fallback), "/")) {
+		w.Header().Del("Cache-Control")
+		return false
+	}
+	return true
+}
+
+// findMount find the static mount with the longest url prefix that matches the url path. The content root
+// is mounted on '/' by default
+func (s *Server) findMount(urlPath string) (*staticMount, string) {
+	for _, m := range s.staticMounts {
+		if name, ok := m.name(urlPath); ok {
+			return m, name
+		}
+	}
+	m := s.contentMount()
+	name, _ := m.name(urlPath)
+	return m, name
+}
+
+// contentMount get the static mount of the content root
+func (s *Server) contentMount() *staticMount {
+	m := &staticMount{prefix: "/", opt: s.staticOpt}
+	if s.webFS != nil {
+		m.fsys = s.subFS("www")
+	} else {
+		m.fsys = os.DirFS(s.contentRoot)
+	}
+	return m
+}
+
+// processStaticRequest serve the static file of the request. It returns false if the file is not found
+func (s *Server) processStaticRequest(w http.ResponseWriter, r *http.Request) bool {
+	// the other methods are left to the routes, for example the WebDAV handler
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	if s.assets != nil && s.assets.serve(w, r, s.staticOpt.AllowHidden) {
+		return true
+	}
+	m, name := s.findMount(ClearPath(r.URL.Path))
+	return m.serve(s, w, r, name)
+}
+
+// processStaticFallback serve the fallback file of the static mount that matches the request
+func (s *Server) processStaticFallback(w http.ResponseWriter, r *http.Request) bool {
+	m, _ := s.findMount(ClearPath(r.URL.Path))
+	return m.serveFallback(s, w, r)
+}
+
+// addMount add the static mount, the mounts are sorted by the length of the url prefix
+func (s *Server) addMount(m *staticMount) {
+	s.staticMounts = append(s.staticMounts, m)
+	sort.SliceStable(s.staticMounts, func(i, j int) bool {
+		return len(s.staticMounts[i].prefix) > len(s.staticMounts[j].prefix)
+	})
+}
+
+// staticPrefix clear the url prefix of the static mount
+func staticPrefix(prefix string) string {
+	prefix = EnsurePrefix(ClearPath(prefix), "/")
+	if prefix != "/" {
+		prefix = strings.TrimRight(prefix, "/")
+	}
+	return prefix
+}
+
+// SetStaticOption set the options of the static files in the content root
+func (s *Server) SetStaticOption(opt *StaticOption) {
+	s.assertUnlocked()
+	assert.NotNil("opt", opt)
+	s.staticOpt = *opt
+}
+
+// MapStatic mount the static directory on the url prefix. The relative directory is relative to the web root,
+// and it's read from the web root file system if it's set by UseFS
+func (s *Server) MapStatic(prefix, dir string, opt *StaticOption) {
+	s.assertUnlocked()
+	assert.NotEmpty("dir", dir)
+	var fsys fs.FS
+	if filepath.IsAbs(dir) {
+		fsys = os.DirFS(dir)
+	} else if s.webFS != nil {
+		fsys = s.subFS(dir)
+	} else {
+		fsys = os.DirFS(s.MapRootPath(dir))
+	}
+	s.MapStaticFS(prefix, fsys, opt)
+}
+
+// MapStaticFS mount the static file system on the url prefix
+func (s *Server) MapStaticFS(prefix string, fsys fs.FS, opt *StaticOption) {
+	s.assertUnlocked()
+	assert.NotNil("fsys", fsys)
+	m := &staticMount{prefix: staticPrefix(prefix), fsys: fsys}
+	if opt != nil {
+		m.opt = *opt
+	}
+	s.addMount(m)
+}
+
+// MapStatic mount the static directory of the area on the url prefix under the area path. The relative
+// directory is relative to the area directory, for example a.MapStatic("/static", "www", nil)
+func (a *Area) MapStatic(prefix, dir string, opt *StaticOption) {
+	assert.NotEmpty("dir", dir)
+	if !filepath.IsAbs(dir) {
+		dir = strings.TrimLeft(a.Key(), "/") + "/" + dir
+	}
+	a.server.MapStatic(a.pathPrefix+staticPrefix(prefix), dir, opt)
+}
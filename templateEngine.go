@@ -0,0 +1,130 @@
+package mego
+
+import (
+	"html/template"
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/simbory/mego/assert"
+	"github.com/simbory/mego/views"
+)
+
+// TemplateEngine the template engine that renders the view files with a file extension
+type TemplateEngine interface {
+	// AddFunc add the view function. The functions are added before any view is rendered
+	AddFunc(name string, viewFunc interface{})
+	// RenderFuncs render the view and write the result to the writer. The view functions in funcs override
+	// the functions added by AddFunc for this rendering only
+	RenderFuncs(w io.Writer, viewName string, data interface{}, funcs template.FuncMap) error
+}
+
+// TemplateEngineFactory create the template engine of the view directory. The view files are read from fsys if
+// it's not nil, otherwise they are read from viewDir on the disk. ext is the file extension of the view files
+type TemplateEngineFactory func(viewDir string, fsys fs.FS, ext string) (TemplateEngine, error)
+
+// HTMLTemplateEngine the factory of the default template engine based on html/template, it supports the layouts
+func HTMLTemplateEngine(viewDir string, fsys fs.FS, ext string) (TemplateEngine, error) {
+	var engine *views.ViewEngine
+	var err error
+	if fsys != nil {
+		engine, err = views.NewEngineFS(fsys, ext)
+	} else {
+		engine, err = views.NewEngine(viewDir, ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+// TextTemplateEngine the factory of the template engine based on text/template for the non-HTML views,
+// for example the plain text emails. It supports the layouts and the partial views like the default engine
+func TextTemplateEngine(viewDir string, fsys fs.FS, ext string) (TemplateEngine, error) {
+	var engine *views.TextEngine
+	var err error
+	if fsys != nil {
+		engine, err = views.NewTextEngineFS(fsys, ext)
+	} else {
+		engine, err = views.NewTextEngine(viewDir, ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+// templateEngineSetting the template engine registered with a file extension
+type templateEngineSetting struct {
+	factory     TemplateEngineFactory
+	contentType string
+}
+
+// templateEngine the template engine created by the factory
+type templateEngine struct {
+	engine      TemplateEngine
+	contentType string
+}
+
+// viewExt get the lower case file extension that starts with '.'
+func viewExt(ext string) string {
+	assert.NotEmpty("ext", ext)
+	return EnsurePrefix(strings.ToLower(ext), ".")
+}
+
+// AddTemplateEngine add the template engine that renders the views with the file extension ext, for example
+// ".gotxt". The view result has the content type contentType. The views without the extension or with the
+// extension '.gohtml' are rendered by the default engine
+func (e *ViewEngine) AddTemplateEngine(ext, contentType string, factory TemplateEngineFactory) {
+	assert.NotNil("factory", factory)
+	ext = viewExt(ext)
+	if len(contentType) == 0 {
+		contentType = "text/plain"
+	}
+	if e.settings == nil {
+		e.settings = make(map[string]*templateEngineSetting)
+	}
+	e.settings[ext] = &templateEngineSetting{factory: factory, contentType: contentType}
+	if e.engine != nil {
+		e.addEngine(ext, e.settings[ext])
+	}
+}
+
+// addEngine create the template engine with the setting and add the view functions to the engine
+func (e *ViewEngine) addEngine(ext string, setting *templateEngineSetting) {
+	engine, err := setting.factory(e.viewDir, e.fsys, ext)
+	assert.PanicErr(err)
+	for name, f := range e.viewFunc {
+		engine.AddFunc(name, f)
+	}
+	if e.engines == nil {
+		e.engines = make(map[string]*templateEngine)
+	}
+	e.engines[ext] = &templateEngine{engine: engine, contentType: setting.contentType}
+}
+
+// findEngine find the template engine of the view by the file extension of the view name
+func (e *ViewEngine) findEngine(viewName string) (TemplateEngine, string) {
+	i := strings.LastIndexByte(viewName, '.')
+	if i > strings.LastIndexByte(viewName, '/') {
+		if engine, ok := e.engines[strings.ToLower(viewName[i:])]; ok {
+			return engine.engine, engine.contentType
+		}
+	}
+	return e.engine, "text/html"
+}
+
+// AddTemplateEngine add the template engine that renders the server views with the file extension ext.
+// The areas use the template engines of the server unless they add the engines with the same extension
+func (s *Server) AddTemplateEngine(ext, contentType string, factory TemplateEngineFactory) {
+	s.assertUnlocked()
+	s.initViewEngine()
+	s.viewEngine.AddTemplateEngine(ext, contentType, factory)
+}
+
+// AddTemplateEngine add the template engine that renders the area views with the file extension ext
+func (a *Area) AddTemplateEngine(ext, contentType string, factory TemplateEngineFactory) {
+	a.server.assertUnlocked()
+	a.initViewEngine()
+	a.viewEngine.AddTemplateEngine(ext, contentType, factory)
+}
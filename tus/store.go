@@ -0,0 +1,238 @@
+package tus
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simbory/mego"
+)
+
+// Upload the resumable upload
+type Upload struct {
+	// ID the upload id in the upload url
+	ID string `json:"id"`
+	// Size the total size of the upload
+	Size int64 `json:"size"`
+	// Offset the number of the bytes that are received
+	Offset int64 `json:"offset"`
+	// Metadata the metadata of the 'Upload-Metadata' header, for example 'filename' and 'filetype'
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// CreatedAt the creation time of the upload
+	CreatedAt time.Time `json:"createdAt"`
+	// ExpiresAt the expiration time of the upload, it's zero if the upload never expires
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+	// Handled indicates that the OnComplete hook of the completed upload returned without panic
+	Handled  bool `json:"handled,omitempty"`
+	dataPath string
+}
+
+// Completed check if all the bytes of the upload are received
+func (u *Upload) Completed() bool {
+	return u.Offset == u.Size
+}
+
+// expired check if the upload is expired
+func (u *Upload) expired(now time.Time) bool {
+	return !u.ExpiresAt.IsZero() && now.After(u.ExpiresAt)
+}
+
+// FileName get the sanitized file name of the 'filename' metadata
+func (u *Upload) FileName() string {
+	return mego.SanitizeFileName(u.Metadata["filename"])
+}
+
+// UploadFile open the completed upload as the uploaded file, so it can be saved like the files posted by the
+// form. The caller closes the file
+func (u *Upload) UploadFile() (*mego.UploadFile, error) {
+	if !u.Completed() {
+		return nil, errIncomplete
+	}
+	f, err := os.Open(u.dataPath)
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if err != nil {
+		contentType = "application/octet-stream"
+	}
+	return &mego.UploadFile{FileName: u.FileName(), Size: u.Size, File: f, ContentType: contentType}, nil
+}
+
+var (
+	errIncomplete = errors.New("tus: the upload is not completed")
+	errBusy       = errors.New("tus: the upload is being written by another request")
+	idReg         = regexp.MustCompile("^[0-9a-f]{32}$")
+)
+
+// store the file system store of the uploads. The data of the upload is saved in '<id>.bin' and the info is
+// saved in '<id>.info'
+type store struct {
+	dir  string
+	lock sync.Mutex
+	busy map[string]bool
+}
+
+func newStore(dir string) *store {
+	return &store{dir: dir, busy: make(map[string]bool)}
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func (s *store) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".info")
+}
+
+func (s *store) dataPath(id string) string {
+	return filepath.Join(s.dir, id+".bin")
+}
+
+// create create the empty upload
+func (s *store) create(u *Upload) error {
+	if err := os.MkdirAll(s.dir, 0777); err != nil {
+		return err
+	}
+	u.ID = newID()
+	u.dataPath = s.dataPath(u.ID)
+	f, err := os.OpenFile(u.dataPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return s.save(u)
+}
+
+// save save the upload info, the info file is replaced atomically
+func (s *store) save(u *Upload) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	tmp := s.infoPath(u.ID) + ".tmp"
+	if err = os.WriteFile(tmp, data, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.infoPath(u.ID))
+}
+
+// get get the upload of the id. It returns os.ErrNotExist if the upload does not exist or it's expired
+func (s *store) get(id string) (*Upload, error) {
+	if !idReg.MatchString(id) {
+		return nil, os.ErrNotExist
+	}
+	data, err := os.ReadFile(s.infoPath(id))
+	if err != nil {
+		return nil, err
+	}
+	u := &Upload{}
+	if err = json.Unmarshal(data, u); err != nil {
+		return nil, err
+	}
+	u.dataPath = s.dataPath(id)
+	if u.expired(time.Now()) {
+		s.remove(id)
+		return nil, os.ErrNotExist
+	}
+	return u, nil
+}
+
+// acquire mark the upload as being written. It returns false if the upload is written by another request
+func (s *store) acquire(id string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.busy[id] {
+		return false
+	}
+	s.busy[id] = true
+	return true
+}
+
+func (s *store) release(id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.busy, id)
+}
+
+// write append the data to the upload at the current offset. The offset is saved even if the data is not
+// received completely, so the client can resume the upload
+func (s *store) write(u *Upload, r io.Reader) (int64, error) {
+	f, err := os.OpenFile(u.dataPath, os.O_WRONLY, 0666)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = f.Seek(u.Offset, io.SeekStart); err != nil {
+		f.Close()
+		return 0, err
+	}
+	n, err := io.Copy(f, io.LimitReader(r, u.Size-u.Offset))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	u.Offset += n
+	if saveErr := s.save(u); err == nil {
+		err = saveErr
+	}
+	return n, err
+}
+
+// markHandled save the upload that is handled by the OnComplete hook. Nothing is saved if the upload is removed
+// by the hook
+func (s *store) markHandled(u *Upload) error {
+	if _, err := os.Stat(s.infoPath(u.ID)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return s.save(u)
+}
+
+// remove remove the data and the info of the upload
+func (s *store) remove(id string) error {
+	if !idReg.MatchString(id) {
+		return os.ErrNotExist
+	}
+	err := os.Remove(s.infoPath(id))
+	if dataErr := os.Remove(s.dataPath(id)); err == nil {
+		err = dataErr
+	}
+	return err
+}
+
+// purge remove the expired uploads
+func (s *store) purge() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), ".info")
+		if id == entry.Name() || !idReg.MatchString(id) {
+			continue
+		}
+		// get removes the expired upload
+		s.get(id)
+	}
+}
@@ -0,0 +1,332 @@
+// Package tus implements the resumable upload endpoint of the tus 1.0 protocol (https://tus.io) with the
+// creation, termination and expiration extensions. The uploads are saved in the directory under the web root.
+//
+// The handler is registered on the upload url and the urls of the uploads, for example:
+//
+//	uploads := tus.New(server, &tus.Config{
+//		OnComplete: func(ctx *mego.HttpCtx, upload *tus.Upload) { ... },
+//	})
+//	server.Route("/files", uploads)
+//	server.Route("/files/*pathInfo", uploads)
+package tus
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simbory/mego"
+	"github.com/simbory/mego/assert"
+)
+
+const (
+	// Version the supported version of the tus protocol
+	Version = "1.0.0"
+	// offsetContentType the content type of the PATCH request
+	offsetContentType = "application/offset+octet-stream"
+	// purgeInterval the min interval of removing the expired uploads
+	purgeInterval = time.Minute
+)
+
+// Config the config of the resumable upload handler
+type Config struct {
+	// Dir the directory of the uploads, the relative directory is relative to the web root. The default
+	// directory is 'uploads'
+	Dir string
+	// MaxSize the max size of the upload, 0 means no limit
+	MaxSize int64
+	// Expiration the duration that the incomplete upload is kept after it's created, 0 means never expire
+	Expiration time.Duration
+	// OnComplete the hook that is executed when all the bytes of the upload are received. If the hook panics, it's
+	// executed again by the next HEAD or PATCH request of the upload, for example when the client resumes the upload.
+	// The completed upload is kept until it's removed by Handler.Remove or it's expired
+	OnComplete func(ctx *mego.HttpCtx, upload *Upload)
+}
+
+// Handler the resumable upload handler
+type Handler struct {
+	config    Config
+	store     *store
+	purgeLock sync.Mutex
+	lastPurge time.Time
+}
+
+// New create the resumable upload handler of the server
+func New(server *mego.Server, config *Config) *Handler {
+	assert.NotNil("server", server)
+	assert.NotNil("config", config)
+	h := &Handler{config: *config}
+	dir := h.config.Dir
+	if len(dir) == 0 {
+		dir = "uploads"
+	}
+	if !filepath.IsAbs(dir) {
+		dir = server.MapRootPath(dir)
+	}
+	h.store = newStore(dir)
+	return h
+}
+
+// Get get the upload of the id
+func (h *Handler) Get(id string) (*Upload, error) {
+	return h.store.get(id)
+}
+
+// Remove remove the upload of the id, for example after the completed upload is saved
+func (h *Handler) Remove(id string) error {
+	return h.store.remove(id)
+}
+
+// tusResult the result that executes the tus request
+type tusResult struct {
+	h   *Handler
+	ctx *mego.HttpCtx
+	id  string
+}
+
+// ExecResult execute the tus request
+func (tr *tusResult) ExecResult(w http.ResponseWriter, r *http.Request) {
+	tr.h.serve(tr.ctx, w, r, tr.id)
+}
+
+// ProcessRequest process the tus request. The upload id is the route parameter 'pathInfo'
+func (h *Handler) ProcessRequest(ctx *mego.HttpCtx) interface{} {
+	return &tusResult{h: h, ctx: ctx, id: strings.Trim(ctx.RouteVar("pathInfo"), "/")}
+}
+
+func (h *Handler) serve(ctx *mego.HttpCtx, w http.ResponseWriter, r *http.Request, id string) {
+	w.Header().Set("Tus-Resumable", Version)
+	method := r.Method
+	// the clients that cannot send PATCH and DELETE override the method of POST
+	if override := r.Header.Get("X-HTTP-Method-Override"); len(override) > 0 && method == "POST" {
+		method = strings.ToUpper(override)
+	}
+	if method == "OPTIONS" {
+		h.options(w)
+		return
+	}
+	if r.Header.Get("Tus-Resumable") != Version {
+		w.Header().Set("Tus-Version", Version)
+		writeStatus(w, http.StatusPreconditionFailed)
+		return
+	}
+	switch {
+	case method == "POST" && len(id) == 0:
+		h.create(w, r)
+	case method == "HEAD" && len(id) > 0:
+		h.head(ctx, w, id)
+	case method == "PATCH" && len(id) > 0:
+		h.patch(ctx, w, r, id)
+	case method == "DELETE" && len(id) > 0:
+		h.terminate(w, id)
+	default:
+		writeStatus(w, http.StatusMethodNotAllowed)
+	}
+}
+
+// writeStatus write the status code without the body
+func writeStatus(w http.ResponseWriter, code int) {
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(code)
+}
+
+// writeExpires write the 'Upload-Expires' header
+func writeExpires(w http.ResponseWriter, u *Upload) {
+	if !u.ExpiresAt.IsZero() {
+		w.Header().Set("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+func (h *Handler) options(w http.ResponseWriter) {
+	w.Header().Set("Tus-Version", Version)
+	extensions := "creation,termination"
+	if h.config.Expiration > 0 {
+		extensions = extensions + ",expiration"
+	}
+	w.Header().Set("Tus-Extension", extensions)
+	if h.config.MaxSize > 0 {
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.config.MaxSize, 10))
+	}
+	writeStatus(w, http.StatusNoContent)
+}
+
+// parseMetadata parse the 'Upload-Metadata' header, for example 'filename d29ybGQ=,is_confidential'
+func parseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+		parts := strings.SplitN(pair, " ", 2)
+		var value []byte
+		if len(parts) == 2 {
+			var err error
+			if value, err = base64.StdEncoding.DecodeString(strings.TrimSpace(parts[1])); err != nil {
+				return nil, err
+			}
+		}
+		metadata[parts[0]] = string(value)
+	}
+	return metadata, nil
+}
+
+// formatMetadata format the metadata of the 'Upload-Metadata' header
+func formatMetadata(metadata map[string]string) string {
+	var pairs []string
+	for k, v := range metadata {
+		if len(v) == 0 {
+			pairs = append(pairs, k)
+		} else {
+			pairs = append(pairs, k+" "+base64.StdEncoding.EncodeToString([]byte(v)))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// purge remove the expired uploads at most once in purgeInterval
+func (h *Handler) purge() {
+	if h.config.Expiration <= 0 {
+		return
+	}
+	h.purgeLock.Lock()
+	if time.Since(h.lastPurge) < purgeInterval {
+		h.purgeLock.Unlock()
+		return
+	}
+	h.lastPurge = time.Now()
+	h.purgeLock.Unlock()
+	go h.store.purge()
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	h.purge()
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		writeStatus(w, http.StatusBadRequest)
+		return
+	}
+	if h.config.MaxSize > 0 && size > h.config.MaxSize {
+		writeStatus(w, http.StatusRequestEntityTooLarge)
+		return
+	}
+	metadata, err := parseMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		writeStatus(w, http.StatusBadRequest)
+		return
+	}
+	u := &Upload{Size: size, Metadata: metadata, CreatedAt: time.Now()}
+	if h.config.Expiration > 0 {
+		u.ExpiresAt = u.CreatedAt.Add(h.config.Expiration)
+	}
+	assert.PanicErr(h.store.create(u))
+	w.Header().Set("Location", path.Join(strings.TrimRight(r.URL.Path, "/"), u.ID))
+	writeExpires(w, u)
+	writeStatus(w, http.StatusCreated)
+}
+
+func (h *Handler) head(ctx *mego.HttpCtx, w http.ResponseWriter, id string) {
+	u, err := h.store.get(id)
+	if err != nil {
+		writeStatus(w, http.StatusNotFound)
+		return
+	}
+	// the client that got the error of the completion hook checks the offset before it resumes the upload
+	if u.Completed() && !u.Handled && h.config.OnComplete != nil && h.store.acquire(id) {
+		defer h.store.release(id)
+		if u, err = h.store.get(id); err != nil {
+			writeStatus(w, http.StatusNotFound)
+			return
+		}
+		h.complete(ctx, u)
+		if _, err = h.store.get(id); err != nil {
+			// the upload is removed by the hook
+			writeStatus(w, http.StatusNotFound)
+			return
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(u.Size, 10))
+	if len(u.Metadata) > 0 {
+		w.Header().Set("Upload-Metadata", formatMetadata(u.Metadata))
+	}
+	writeExpires(w, u)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) patch(ctx *mego.HttpCtx, w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != offsetContentType {
+		writeStatus(w, http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		writeStatus(w, http.StatusBadRequest)
+		return
+	}
+	if !h.store.acquire(id) {
+		writeStatus(w, http.StatusLocked)
+		return
+	}
+	defer h.store.release(id)
+	u, err := h.store.get(id)
+	if err != nil {
+		writeStatus(w, http.StatusNotFound)
+		return
+	}
+	if offset != u.Offset {
+		writeStatus(w, http.StatusConflict)
+		return
+	}
+	if r.ContentLength > 0 && offset+r.ContentLength > u.Size {
+		writeStatus(w, http.StatusRequestEntityTooLarge)
+		return
+	}
+	_, err = h.store.write(u, r.Body)
+	if err != nil && !errors.Is(err, os.ErrNotExist) && u.Offset == offset {
+		// nothing is received, the client retries the request
+		writeStatus(w, http.StatusInternalServerError)
+		return
+	}
+	if u.Completed() {
+		h.complete(ctx, u)
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	writeExpires(w, u)
+	writeStatus(w, http.StatusNoContent)
+}
+
+// complete execute the OnComplete hook of the completed upload. The upload is marked as handled only after the
+// hook returns, so the hook is executed again by the next HEAD or PATCH request after the hook panics
+func (h *Handler) complete(ctx *mego.HttpCtx, u *Upload) {
+	if h.config.OnComplete == nil || u.Handled {
+		return
+	}
+	h.config.OnComplete(ctx, u)
+	u.Handled = true
+	assert.PanicErr(h.store.markHandled(u))
+}
+
+func (h *Handler) terminate(w http.ResponseWriter, id string) {
+	if !h.store.acquire(id) {
+		writeStatus(w, http.StatusLocked)
+		return
+	}
+	defer h.store.release(id)
+	if _, err := h.store.get(id); err != nil {
+		writeStatus(w, http.StatusNotFound)
+		return
+	}
+	assert.PanicErr(h.store.remove(id))
+	writeStatus(w, http.StatusNoContent)
+}
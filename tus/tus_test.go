@@ -0,0 +1,88 @@
+package tus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/simbory/mego"
+)
+
+// request send the tus request to the handler, the panic of the handler is returned as the status 500
+func request(h *Handler, method, url string, header map[string]string, body string) (rec *httptest.ResponseRecorder) {
+	r := httptest.NewRequest(method, url, strings.NewReader(body))
+	r.Header.Set("Tus-Resumable", Version)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	rec = httptest.NewRecorder()
+	defer func() {
+		if recover() != nil {
+			rec.Code = http.StatusInternalServerError
+		}
+	}()
+	h.serve(nil, rec, r, strings.Trim(strings.TrimPrefix(r.URL.Path, "/files"), "/"))
+	return rec
+}
+
+func TestCompleteRetry(t *testing.T) {
+	calls := 0
+	h := New(mego.NewServer(t.TempDir(), ":0"), &Config{
+		OnComplete: func(ctx *mego.HttpCtx, upload *Upload) {
+			calls++
+			if calls == 1 {
+				panic("the hook failed")
+			}
+		},
+	})
+	rec := request(h, "POST", "/files", map[string]string{"Upload-Length": "5"}, "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	url := rec.Header().Get("Location")
+	patch := func(offset, body string) int {
+		return request(h, "PATCH", url, map[string]string{
+			"Content-Type":  offsetContentType,
+			"Upload-Offset": offset,
+		}, body).Code
+	}
+	if code := patch("0", "hello"); code != http.StatusInternalServerError || calls != 1 {
+		t.Fatalf("unexpected status %d, calls %d", code, calls)
+	}
+	// the client checks the offset before it resumes the upload
+	rec = request(h, "HEAD", url, nil, "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Upload-Offset") != "5" || calls != 2 {
+		t.Fatalf("unexpected status %d, offset %s, calls %d", rec.Code, rec.Header().Get("Upload-Offset"), calls)
+	}
+	u, err := h.Get(path.Base(url))
+	if err != nil || !u.Handled {
+		t.Fatalf("the upload is not handled: %v", err)
+	}
+	if code := patch("5", ""); code != http.StatusNoContent || calls != 2 {
+		t.Fatalf("unexpected status %d, calls %d", code, calls)
+	}
+}
+
+func TestCompleteRetryPatch(t *testing.T) {
+	calls := 0
+	h := New(mego.NewServer(t.TempDir(), ":0"), &Config{
+		OnComplete: func(ctx *mego.HttpCtx, upload *Upload) {
+			calls++
+			if calls == 1 {
+				panic("the hook failed")
+			}
+		},
+	})
+	url := request(h, "POST", "/files", map[string]string{"Upload-Length": "5"}, "").Header().Get("Location")
+	header := map[string]string{"Content-Type": offsetContentType, "Upload-Offset": "0"}
+	if code := request(h, "PATCH", url, header, "hello").Code; code != http.StatusInternalServerError {
+		t.Fatalf("unexpected status %d", code)
+	}
+	// the empty PATCH request at the end of the upload executes the hook again
+	header["Upload-Offset"] = "5"
+	if code := request(h, "PATCH", url, header, "").Code; code != http.StatusNoContent || calls != 2 {
+		t.Fatalf("unexpected status %d, calls %d", code, calls)
+	}
+}
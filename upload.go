@@ -0,0 +1,319 @@
+package mego
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/simbory/mego/assert"
+)
+
+// UploadOption the options of the uploaded files
+type UploadOption struct {
+	// MaxRequestSize the max size of the request body, the default value is 32MB and the negative value means
+	// no limit. The status code 413 is sent if the request body is too large
+	MaxRequestSize int64
+	// MaxFileSize the max size of each uploaded file, 0 means no limit. The status code 413 is sent if any file
+	// is too large
+	MaxFileSize int64
+	// TempDir the directory of the temporary files, the default directory is os.TempDir()
+	TempDir string
+	// AllowedTypes the allowed MIME types of the files that are sniffed from the file content, for example
+	// "image/png" or "image/*". All the types are allowed if it's empty
+	AllowedTypes []string
+}
+
+const (
+	// defaultMaxRequestSize the default max size of the request body
+	defaultMaxRequestSize = 32 << 20
+	// maxValueSize the max size of the form values that are not files
+	maxValueSize = 10 << 20
+	// sniffLen the length of the content that is used to detect the MIME type
+	sniffLen = 512
+)
+
+var (
+	// ErrUploadTooLarge the error of the request body or the file that exceeds the size limit
+	ErrUploadTooLarge = errors.New("mego: the uploaded content is too large")
+	// ErrUploadType the error of the file whose MIME type is not allowed
+	ErrUploadType = errors.New("mego: the type of the uploaded file is not allowed")
+)
+
+// maxRequestSize get the max size of the request body
+func (opt *UploadOption) maxRequestSize() int64 {
+	if opt.MaxRequestSize == 0 {
+		return defaultMaxRequestSize
+	}
+	return opt.MaxRequestSize
+}
+
+// allowed check if the MIME type is in the allow list
+func (opt *UploadOption) allowed(contentType string) bool {
+	if len(opt.AllowedTypes) == 0 {
+		return true
+	}
+	for _, t := range opt.AllowedTypes {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "*/*" || t == contentType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(contentType, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// sniff detect the MIME type of the content without the parameters
+func sniff(head []byte) string {
+	contentType := http.DetectContentType(head)
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	return contentType
+}
+
+var reservedFileNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizeFileName get the safe file name from the file name that is sent by the client. The directories, the
+// control characters and the reserved characters are removed, and the name never starts with '.'
+func SanitizeFileName(name string) string {
+	name = path.Base(strings.Replace(name, "\\", "/", -1))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, " .")
+	base := name
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	if reservedFileNames[strings.ToUpper(base)] {
+		name = "_" + name
+	}
+	if len(name) > 255 {
+		ext := path.Ext(name)
+		if len(ext) > 32 {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:255-len(ext)], "") + ext
+	}
+	if len(name) == 0 {
+		return "file"
+	}
+	return name
+}
+
+// receiveFile stream the file part to the temporary file
+func (ctx *HttpCtx) receiveFile(part *multipart.Part, opt *UploadOption) (*UploadFile, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+	file := &UploadFile{
+		FileName:    SanitizeFileName(part.FileName()),
+		ContentType: sniff(head),
+		Header:      &multipart.FileHeader{Filename: part.FileName(), Header: part.Header},
+		maxSize:     opt.MaxFileSize,
+	}
+	if !opt.allowed(file.ContentType) {
+		file.Error = ErrUploadType
+		_, err = io.Copy(io.Discard, part)
+		return file, err
+	}
+	tmp, err := os.CreateTemp(opt.TempDir, "mego-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	ctx.OnEnd(func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	})
+	var src io.Reader = io.MultiReader(bytes.NewReader(head), part)
+	if opt.MaxFileSize > 0 {
+		src = io.LimitReader(src, opt.MaxFileSize+1)
+	}
+	size, err := io.Copy(tmp, src)
+	if err != nil {
+		return nil, err
+	}
+	if opt.MaxFileSize > 0 && size > opt.MaxFileSize {
+		return nil, ErrUploadTooLarge
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	file.Size = size
+	file.File = tmp
+	file.Header.Size = size
+	return file, nil
+}
+
+// parsedFiles get the files of the form that is parsed by ParseMultipartForm before
+func (ctx *HttpCtx) parsedFiles(opt *UploadOption) (map[string][]*UploadFile, error) {
+	files := make(map[string][]*UploadFile)
+	var total int64
+	for name, headers := range ctx.req.MultipartForm.File {
+		for _, h := range headers {
+			// the body was read without the limit of the request size, so the total size of the files is checked
+			total += h.Size
+			if max := opt.maxRequestSize(); max > 0 && total > max {
+				return nil, ErrUploadTooLarge
+			}
+			if opt.MaxFileSize > 0 && h.Size > opt.MaxFileSize {
+				return nil, ErrUploadTooLarge
+			}
+			f, err := h.Open()
+			if err != nil {
+				files[name] = append(files[name], &UploadFile{FileName: SanitizeFileName(h.Filename), Error: err, Header: h})
+				continue
+			}
+			ctx.OnEnd(func() {
+				f.Close()
+			})
+			head := make([]byte, sniffLen)
+			n, _ := io.ReadFull(f, head)
+			f.Seek(0, io.SeekStart)
+			file := &UploadFile{
+				FileName:    SanitizeFileName(h.Filename),
+				Size:        h.Size,
+				File:        f,
+				Header:      h,
+				ContentType: sniff(head[:n]),
+				maxSize:     opt.MaxFileSize,
+			}
+			if !opt.allowed(file.ContentType) {
+				file.Error = ErrUploadType
+			}
+			files[name] = append(files[name], file)
+		}
+	}
+	return files, nil
+}
+
+// parseFiles parse the multipart request once. The files are streamed to the temporary files that are removed
+// after the request is ended, and the other form values can be read by FormValue
+func (ctx *HttpCtx) parseFiles() error {
+	if ctx.uploads != nil || ctx.uploadErr != nil {
+		return ctx.uploadErr
+	}
+	ctx.uploads, ctx.uploadErr = ctx.readFiles()
+	if ctx.uploadErr != nil {
+		ctx.uploads = nil
+		if errors.Is(ctx.uploadErr, ErrUploadTooLarge) {
+			ctx.Server.err413Handler(ctx.res, ctx.req)
+			ctx.End()
+		}
+	}
+	return ctx.uploadErr
+}
+
+func (ctx *HttpCtx) readFiles() (map[string][]*UploadFile, error) {
+	opt := &ctx.Server.uploadOpt
+	r := ctx.req
+	if r.MultipartForm != nil {
+		// the form is parsed by ParseMultipartForm, for example by the form value of the anti-forgery token
+		return ctx.parsedFiles(opt)
+	}
+	if max := opt.maxRequestSize(); max > 0 {
+		if r.ContentLength > max {
+			return nil, ErrUploadTooLarge
+		}
+		r.Body = http.MaxBytesReader(ctx.res, r.Body, max)
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]*UploadFile)
+	values := make(url.Values)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, uploadErr(err)
+		}
+		name := part.FormName()
+		if len(name) == 0 {
+			continue
+		}
+		if len(part.FileName()) == 0 {
+			data, err := io.ReadAll(io.LimitReader(part, maxValueSize+1))
+			if err != nil {
+				return nil, uploadErr(err)
+			}
+			if len(data) > maxValueSize {
+				return nil, ErrUploadTooLarge
+			}
+			values.Add(name, string(data))
+			continue
+		}
+		file, err := ctx.receiveFile(part, opt)
+		if err != nil {
+			return nil, uploadErr(err)
+		}
+		files[name] = append(files[name], file)
+	}
+	// the form values are kept for FormValue and PostFormValue
+	if r.Form == nil {
+		r.Form = make(url.Values)
+		for k, v := range r.URL.Query() {
+			r.Form[k] = v
+		}
+	}
+	if r.PostForm == nil {
+		r.PostForm = make(url.Values)
+	}
+	for k, v := range values {
+		r.Form[k] = append(r.Form[k], v...)
+		r.PostForm[k] = append(r.PostForm[k], v...)
+	}
+	r.MultipartForm = &multipart.Form{Value: values}
+	return files, nil
+}
+
+// isMultipart check if the request body is the multipart form
+func isMultipart(r *http.Request) bool {
+	return strings.HasPrefix(strings.ToLower(r.Header.Get("Content-Type")), "multipart/form-data")
+}
+
+// uploadErr convert the error of the request body that exceeds the limit to ErrUploadTooLarge
+func uploadErr(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return ErrUploadTooLarge
+	}
+	return err
+}
+
+// PostFiles get the files of the multiple file input. The files are streamed to the temporary files, and the
+// status code 413 is sent if the request body or any file exceeds the size limit. The file whose MIME type is
+// not allowed has the error ErrUploadType
+func (ctx *HttpCtx) PostFiles(formName string) ([]*UploadFile, error) {
+	if err := ctx.parseFiles(); err != nil {
+		return nil, err
+	}
+	return ctx.uploads[formName], nil
+}
+
+// SetUploadOption set the options of the uploaded files
+func (s *Server) SetUploadOption(opt *UploadOption) {
+	s.assertUnlocked()
+	assert.NotNil("opt", opt)
+	s.uploadOpt = *opt
+}
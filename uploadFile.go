@@ -1,6 +1,8 @@
 package mego
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"mime/multipart"
 	"os"
@@ -8,24 +10,47 @@ import (
 
 // UploadFile the uploaded file struct
 type UploadFile struct {
+	// FileName the sanitized file name without the directories
 	FileName string
 	Size     int64
 	Error    error
 	File     multipart.File
 	Header   *multipart.FileHeader
+	// ContentType the MIME type that is sniffed from the file content
+	ContentType string
+	// SHA256 the hex SHA-256 hash of the file content, it's computed by Save
+	SHA256  string
+	maxSize int64
 }
 
-// Save save the posted file data as a file.
+// Save save the posted file data as a file. The SHA-256 hash of the file is computed during saving
 func (file *UploadFile) Save(path string) error {
 	if file.Error != nil {
 		return file.Error
 	}
+	if file.maxSize > 0 && file.Size > file.maxSize {
+		return ErrUploadTooLarge
+	}
 	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL|os.O_TRUNC, 0666)
 	if err == nil {
 		defer f.Close()
 		if file.File != nil {
-			_, err := io.Copy(f, file.File)
-			return err
+			if _, err = file.File.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			h := sha256.New()
+			var src io.Reader = file.File
+			if file.maxSize > 0 {
+				src = io.LimitReader(src, file.maxSize+1)
+			}
+			n, err := io.Copy(io.MultiWriter(f, h), src)
+			if err != nil {
+				return err
+			}
+			if file.maxSize > 0 && n > file.maxSize {
+				return ErrUploadTooLarge
+			}
+			file.SHA256 = hex.EncodeToString(h.Sum(nil))
 		}
 	}
 	return err
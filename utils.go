@@ -66,3 +66,9 @@ func WorkingDir() string {
 	}
 	return strings.Replace(dir, "\\", "/", -1)
 }
+
+// isDir check if the path is an existing directory
+func isDir(p string) bool {
+	stat, err := os.Stat(p)
+	return err == nil && stat.IsDir()
+}
@@ -1,7 +1,14 @@
 package mego
 
 import (
+	"bytes"
 	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/simbory/mego/assert"
 	"github.com/simbory/mego/views"
@@ -9,31 +16,114 @@ import (
 
 // ViewEngine the mego view engine struct
 type ViewEngine struct {
-	engine   *views.ViewEngine
-	viewDir  string
+	engine  *views.ViewEngine
+	viewDir string
+	// fsys the file system of the views, the views are read from viewDir if it's nil
+	fsys     fs.FS
 	viewFunc template.FuncMap
+	layout   string
+	// locations the view search locations, nil means the default locations
+	locations []string
+	// fallback the engine to search the views that are not found in the current engine
+	fallback *ViewEngine
+	// settings the template engines added by AddTemplateEngine
+	settings map[string]*templateEngineSetting
+	engines  map[string]*templateEngine
+	initLock sync.Mutex
 }
 
 // Render render the view 'viewName' with 'data' and get the view result
 func (e *ViewEngine) Render(viewName string, data interface{}) Result {
-	if e.engine == nil {
-		func(e *ViewEngine) {
-			eg, err := views.NewEngine(e.viewDir, ".gohtml")
-			assert.PanicErr(err)
-			for name, f := range e.viewFunc {
-				eg.AddFunc(name, f)
-			}
-			e.engine = eg
-		}(e)
-	}
+	return e.render(viewName, data, nil)
+}
+
+func (e *ViewEngine) render(viewName string, data interface{}, funcs template.FuncMap) Result {
+	e.init()
 	if len(viewName) == 0 {
 		return nil
 	}
+	engine, contentType := e.findEngine(viewName)
 	return &viewResult{
-		viewName: viewName,
-		data:     data,
-		engine:   e.engine,
+		viewName:    viewName,
+		data:        data,
+		engine:      engine,
+		contentType: contentType,
+		funcs:       funcs,
+	}
+}
+
+// partialRenderer the template engine that renders the view without the default layout, for example the html and
+// the text engines of the views package
+type partialRenderer interface {
+	RenderPartial(writer io.Writer, viewName string, data interface{}, funcs template.FuncMap) error
+}
+
+// renderString render the view and get the result string. The default layout is not applied if partial is true
+func (e *ViewEngine) renderString(viewName string, data interface{}, funcs template.FuncMap, partial bool) (string, error) {
+	assert.NotEmpty("viewName", viewName)
+	e.init()
+	engine, _ := e.findEngine(viewName)
+	buf := &bytes.Buffer{}
+	var err error
+	if p, ok := engine.(partialRenderer); ok && partial {
+		err = p.RenderPartial(buf, viewName, data, funcs)
+	} else {
+		err = engine.RenderFuncs(buf, viewName, data, funcs)
+	}
+	if err != nil {
+		return "", err
 	}
+	return buf.String(), nil
+}
+
+func (e *ViewEngine) init() {
+	e.initLock.Lock()
+	defer e.initLock.Unlock()
+	if e.engine == nil {
+		var eg *views.ViewEngine
+		var err error
+		if e.fsys != nil {
+			eg, err = views.NewEngineFS(e.fsys, ".gohtml")
+		} else {
+			eg, err = views.NewEngine(e.viewDir, ".gohtml")
+		}
+		assert.PanicErr(err)
+		for name, f := range e.viewFunc {
+			eg.AddFunc(name, f)
+		}
+		if len(e.layout) > 0 {
+			eg.SetLayout(e.layout)
+		}
+		if len(e.locations) > 0 {
+			eg.SetLocations(e.locations...)
+		}
+		// the fallback engine is ignored if its view directory does not exist
+		if e.fallback != nil && e.fallback.exists() {
+			e.fallback.init()
+			eg.SetFallback(e.fallback.engine)
+		}
+		e.engine = eg
+		// the template engines of the fallback engine are used unless the engines with the same extension are added
+		if e.fallback != nil {
+			for ext, setting := range e.fallback.settings {
+				if _, ok := e.settings[ext]; !ok {
+					e.addEngine(ext, setting)
+				}
+			}
+		}
+		for ext, setting := range e.settings {
+			e.addEngine(ext, setting)
+		}
+	}
+}
+
+// exists check if the view directory exists
+func (e *ViewEngine) exists() bool {
+	if e.fsys != nil {
+		stat, err := fs.Stat(e.fsys, ".")
+		return err == nil && stat.IsDir()
+	}
+	return isDir(e.viewDir)
 }
 
 // ExtendView extend the view helper functions with 'name' and 'viewFunc'
@@ -44,12 +134,123 @@ func (e *ViewEngine) ExtendView(name string, viewFunc interface{}) {
 	e.viewFunc[name] = viewFunc
 }
 
+// SetLayout set the default layout of the views that don't declare the layout and aren't covered by a '_viewstart' file
+func (e *ViewEngine) SetLayout(layout string) {
+	e.layout = layout
+	if e.engine != nil {
+		e.engine.SetLayout(layout)
+	}
+}
+
+// SetLocations set the directories relative to the view directory where the views, the layouts and the partial views
+// are searched in order. The default locations are the view directory and its 'shared' directory
+func (e *ViewEngine) SetLocations(locations ...string) {
+	assert.Assert("locations", func() bool {
+		return len(locations) > 0
+	})
+	e.locations = locations
+	if e.engine != nil {
+		e.engine.SetLocations(locations...)
+	}
+}
+
 // NewViewEngine create a new view engine in ViewDir with file extension '.gohtml'
 func NewViewEngine(viewDir string) *ViewEngine {
 	assert.NotEmpty("viewDir", viewDir)
 	return &ViewEngine{
 		engine:   nil,
 		viewDir:  viewDir,
-		viewFunc: make(template.FuncMap),
+		viewFunc: template.FuncMap{"flashes": noFlashes},
+	}
+}
+
+// newViewEngine create the view engine of the directory relative to the web root.
+// The views are read from the web root file system if it's set by UseFS
+func (s *Server) newViewEngine(dir string) *ViewEngine {
+	e := NewViewEngine(s.MapRootPath(dir))
+	e.viewFunc["asset"] = s.AssetURL
+	if s.webFS != nil {
+		e.fsys = s.subFS(dir)
+	}
+	return e
+}
+
+// PrecompileViews compile the views of the server and all the areas that have routes when the server starts,
+// so that the server fails to start with a report of all the broken views
+func (s *Server) PrecompileViews() {
+	s.assertUnlocked()
+	s.precompile = true
+}
+
+// CompileViews compile the views of the server and the areas that have routes now, and get all the view errors.
+// The views are compiled when the server starts if PrecompileViews is called
+func (s *Server) CompileViews() error {
+	s.initViewEngine()
+	engines := map[string]*ViewEngine{"views": s.viewEngine}
+	for _, setting := range s.routeSettings {
+		if a := setting.area; a != nil {
+			a.initViewEngine()
+			engines[strings.TrimLeft(a.Key(), "/")+"/views"] = a.viewEngine
+		}
+	}
+	dirs := make([]string, 0, len(engines))
+	for dir := range engines {
+		dirs = append(dirs, dir)
 	}
-}
\ No newline at end of file
+	sort.Strings(dirs)
+	var errs []*views.ViewError
+	for _, dir := range dirs {
+		e := engines[dir]
+		if !e.exists() {
+			continue
+		}
+		e.init()
+		engines := []TemplateEngine{e.engine}
+		for _, engine := range e.engines {
+			engines = append(engines, engine.engine)
+		}
+		for _, engine := range engines {
+			p, ok := engine.(interface{ Precompile() error })
+			if !ok {
+				continue
+			}
+			err := p.Precompile()
+			if ce, ok := err.(*views.CompileError); ok {
+				for _, ve := range ce.Errors {
+					ve.View = path.Join(dir, ve.View)
+					errs = append(errs, ve)
+				}
+			} else if err != nil {
+				errs = append(errs, &views.ViewError{View: dir, Err: err})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return &views.CompileError{Errors: errs}
+	}
+	return nil
+}
+
+// RenderView render the server view with the data and get the result, for example the email body. The view is
+// rendered by the same compiled templates and view functions that render the view results
+func (s *Server) RenderView(viewName string, data interface{}) (string, error) {
+	s.initViewEngine()
+	return s.viewEngine.renderString(viewName, data, nil, false)
+}
+
+// RenderView render the area view with the data and get the result
+func (a *Area) RenderView(viewName string, data interface{}) (string, error) {
+	a.initViewEngine()
+	return a.viewEngine.renderString(viewName, data, nil, false)
+}
+
+// RenderPartial render the view of the current area or server as a partial view without the default layout,
+// the view functions overridden by ExtendView are applied
+func (ctx *HttpCtx) RenderPartial(viewName string, data interface{}) (string, error) {
+	if ctx.area != nil {
+		ctx.area.initViewEngine()
+		return ctx.area.viewEngine.renderString(viewName, data, ctx.viewFuncs, true)
+	}
+	ctx.Server.initViewEngine()
+	return ctx.Server.viewEngine.renderString(viewName, data, ctx.viewFuncs, true)
+}
@@ -0,0 +1,42 @@
+package mego
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderTextPartial(t *testing.T) {
+	s := newTestServer(t, func(srv *Server) {
+		srv.AddTemplateEngine(".gotxt", "text/plain", TextTemplateEngine)
+		srv.Route("/partial", func(ctx *HttpCtx) interface{} {
+			text, err := ctx.RenderPartial("item.gotxt", "a & b")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return ctx.TextResult(text, "text/plain")
+		})
+	})
+	views := s.MapRootPath("views")
+	files := map[string]string{
+		"_viewstart.gotxt": `{{layout "layout"}}`,
+		"layout.gotxt":     `[{{template "body" .}}]`,
+		"item.gotxt":       `item {{.}}`,
+	}
+	assertNoErr := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertNoErr(os.MkdirAll(views, 0777))
+	for name, content := range files {
+		assertNoErr(os.WriteFile(filepath.Join(views, name), []byte(content), 0666))
+	}
+	if text, err := s.RenderView("item.gotxt", "a & b"); err != nil || text != "[item a & b]" {
+		t.Fatalf("unexpected view %q %v", text, err)
+	}
+	if body := serve(s, httptest.NewRequest("GET", "/partial", nil)).Body.String(); body != "item a & b" {
+		t.Fatalf("unexpected partial view %q", body)
+	}
+}
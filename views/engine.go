@@ -8,7 +8,7 @@ import (
 	"github.com/simbory/mego/fswatcher"
 	"html/template"
 	"io"
-	"io/ioutil"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -17,13 +17,23 @@ import (
 	"path"
 )
 
+// templateReg the regex of the {{template}} actions that reference the partial views
+var templateReg = regexp.MustCompile("[{]{2}[ \t]*template[ \t]+\"([^\"]+)\"")
+
 type ViewEngine struct {
 	viewDir  string
+	fsys     fs.FS
 	viewExt  string
 	funcMap  template.FuncMap
 	locker   sync.RWMutex
 	viewMap  map[string]*tplCache
 	compiled bool
+	layout   string
+	dialect  *dialect
+
+	locations  []string
+	fallback   *ViewEngine
+	dependents []*ViewEngine
 
 	watcher *fswatcher.FileWatcher
 }
@@ -51,48 +61,140 @@ func (engine *ViewEngine) addView(name string, v *tplCache) {
 	engine.viewMap[name] = v
 }
 
-func (engine *ViewEngine) getView(name string) (*template.Template, error) {
-	if engine.viewMap == nil {
-		return nil, nil
-	}
+func (engine *ViewEngine) getView(name string, funcs template.FuncMap, partial bool) (viewTemplate, error) {
 	if strings.HasPrefix(name, "/") {
 		name = strings.TrimLeft(name, "/")
 	}
+	engine.locker.RLock()
 	cacheView := engine.viewMap[name]
+	engine.locker.RUnlock()
 	if cacheView == nil {
-		return nil, nil
+		cacheView = engine.compileView(name)
+	}
+	if partial && cacheView.partial != nil {
+		cacheView = cacheView.partial
+	}
+	if cacheView.err != nil || len(funcs) == 0 {
+		return cacheView.tpl, cacheView.err
+	}
+	// the executed template cannot be cloned, so the request funcs are applied on the clone of the source template
+	t, err := cacheView.src.Clone()
+	if err != nil {
+		return nil, err
 	}
-	return cacheView.tpl, cacheView.err
+	return t.Funcs(funcs), nil
 }
 
-func (engine *ViewEngine) getDeep(file, parent string, t *template.Template) (*template.Template, [][]string, error) {
-	var fileAbsPath string
+// compileView compile the view that is not in the view root, but is found in the other view locations
+// or the fallback engines
+func (engine *ViewEngine) compileView(name string) *tplCache {
+	engine.locker.Lock()
+	defer engine.locker.Unlock()
+	if cacheView := engine.viewMap[name]; cacheView != nil {
+		return cacheView
+	}
+	layout, explicit, err := engine.explicitLayout(name)
+	if err != nil {
+		// the view that is not found is not cached, so that it can be added later
+		return &tplCache{err: err}
+	}
+	cache := engine.getTplCache(name, layout)
+	if !explicit {
+		if layout = engine.defaultLayout(name, make(map[string]string)); len(layout) > 0 {
+			page := engine.getTplCache(name, layout)
+			page.partial = cache
+			cache = page
+		}
+	}
+	engine.addView(name, cache)
+	return cache
+}
 
+// viewName get the view name relative to the view root. The relative name ('./' or '../') is relative to the view parent
+func viewName(file, parent string) (string, error) {
+	name := file
 	if strings.HasPrefix(file, "../") || strings.HasPrefix(file, "./") {
-		fileAbsPath = filepath.Join(engine.viewDir, filepath.Dir(parent), file)
-		fileAbsPath = strings.Replace(fileAbsPath, "\\", "/", -1)
-		if !strings.HasPrefix(fileAbsPath, engine.viewDir) {
-			return nil, nil, errors.New("invalid view file:" + file)
+		name = path.Join(path.Dir(parent), file)
+	}
+	name = strings.TrimLeft(path.Clean(name), "/")
+	if name == ".." || strings.HasPrefix(name, "../") {
+		return "", errors.New("invalid view file:" + file)
+	}
+	return name, nil
+}
+
+// searchPaths get the paths where the view is searched, in the order of the view locations of the engine
+// and then the view locations of the fallback engines
+func (engine *ViewEngine) searchPaths(name string) []string {
+	var paths []string
+	for e := engine; e != nil; e = e.fallback {
+		for _, location := range e.locations {
+			paths = append(paths, path.Join(e.viewDir, location, name))
 		}
-	} else if strings.HasPrefix(file, "/") {
-		fileAbsPath = engine.viewDir + strings.TrimLeft(file, "/")
-	} else {
-		fileAbsPath = filepath.Join(engine.viewDir, file)
 	}
-	stat, err := os.Stat(fileAbsPath)
-	if err != nil || stat.IsDir() {
-		return nil, [][]string{}, fmt.Errorf("the partial view '%s' in '%s' cannot be found", file, parent)
+	return paths
+}
+
+// findView find the view file that is referenced by the view parent, and get the engine where the view file is found
+// and the path of the view file in the engine
+func (engine *ViewEngine) findView(file, parent string) (*ViewEngine, string, error) {
+	name, err := viewName(file, parent)
+	if err != nil {
+		return nil, "", err
+	}
+	for e := engine; e != nil; e = e.fallback {
+		for _, location := range e.locations {
+			p := path.Join(location, name)
+			if stat, err := fs.Stat(e.fsys, p); err == nil && !stat.IsDir() {
+				return e, p, nil
+			}
+		}
+	}
+	return nil, "", &NotFoundError{Name: file, Searched: engine.searchPaths(name)}
+}
+
+// readView read the content of the view file that is referenced by the view parent
+func (engine *ViewEngine) readView(file, parent string, files map[string]bool) (string, error) {
+	e, p, err := engine.findView(file, parent)
+	if err != nil {
+		return "", err
+	}
+	return e.readFile(p, files)
+}
+
+// readFile read the content of the file in the view root and add the file to the compiled files.
+// Only the files on the disk are added, because the other files cannot be changed
+func (engine *ViewEngine) readFile(p string, files map[string]bool) (string, error) {
+	data, err := fs.ReadFile(engine.fsys, p)
+	if err != nil {
+		return "", err
+	}
+	if files != nil && engine.watcher != nil {
+		files[path.Join(engine.viewDir, p)] = true
+	}
+	return string(data), nil
+}
+
+func (engine *ViewEngine) getDeep(file, parent string, t viewTemplate, files map[string]bool) (viewTemplate, [][]string, error) {
+	data, err := engine.readView(file, parent, files)
+	if nf, ok := err.(*NotFoundError); ok && len(parent) > 0 {
+		return nil, [][]string{}, fmt.Errorf("the partial view '%s' in '%s' cannot be found, searched paths: %s",
+			file, parent, strings.Join(nf.Searched, ", "))
 	}
-	data, err := ioutil.ReadFile(fileAbsPath)
 	if err != nil {
 		return nil, [][]string{}, err
 	}
-	t, err = t.New(file).Parse(string(data))
+	return engine.parseView(t, file, file, data, files)
+}
+
+// parseView parse the content of the view file as the template 'name', and then load the partial views
+// referenced by the {{template}} actions
+func (engine *ViewEngine) parseView(t viewTemplate, name, file, data string, files map[string]bool) (viewTemplate, [][]string, error) {
+	t, err := t.New(name).Parse(data)
 	if err != nil {
 		return nil, [][]string{}, err
 	}
-	reg := regexp.MustCompile("[{]{2}[ \t]*template[ \t]+\"([^\"]+)\"")
-	allSub := reg.FindAllStringSubmatch(string(data), -1)
+	allSub := templateReg.FindAllStringSubmatch(data, -1)
 	for _, m := range allSub {
 		if len(m) == 2 {
 			name := m[1]
@@ -103,7 +205,7 @@ func (engine *ViewEngine) getDeep(file, parent string, t *template.Template) (*t
 			if look != nil {
 				continue
 			}
-			t, _, err = engine.getDeep(name, file, t)
+			t, _, err = engine.getDeep(name, file, t, files)
 			if err != nil {
 				return nil, [][]string{}, err
 			}
@@ -112,7 +214,7 @@ func (engine *ViewEngine) getDeep(file, parent string, t *template.Template) (*t
 	return t, allSub, nil
 }
 
-func (engine *ViewEngine) getLoop(temp *template.Template, subMods [][]string, others ...string) (t *template.Template, err error) {
+func (engine *ViewEngine) getLoop(temp viewTemplate, subMods [][]string, files map[string]bool, others ...string) (t viewTemplate, err error) {
 	t = temp
 	for _, m := range subMods {
 		if len(m) == 2 {
@@ -124,11 +226,11 @@ func (engine *ViewEngine) getLoop(temp *template.Template, subMods [][]string, o
 			for _, otherFile := range others {
 				if otherFile == m[1] {
 					var subMods1 [][]string
-					t, subMods1, err = engine.getDeep(otherFile, "", t)
+					t, subMods1, err = engine.getDeep(otherFile, "", t, files)
 					if err != nil {
 						return nil, err
 					} else if subMods1 != nil && len(subMods1) > 0 {
-						t, err = engine.getLoop(t, subMods1, others...)
+						t, err = engine.getLoop(t, subMods1, files, others...)
 					}
 					break
 				}
@@ -138,21 +240,38 @@ func (engine *ViewEngine) getLoop(temp *template.Template, subMods [][]string, o
 	return
 }
 
-func (engine *ViewEngine) getTplCache(file string, others ...string) *tplCache {
-	t := template.New(file)
+// getTplCache compile the view file with the layout. The view is compiled without layout if the layout is empty
+func (engine *ViewEngine) getTplCache(file, layout string, others ...string) *tplCache {
+	t := engine.dialect.newTemplate(file)
 	if engine.funcMap != nil {
 		t.Funcs(engine.funcMap)
 	}
 	var subMods [][]string
-	t, subMods, err := engine.getDeep(file, "", t)
+	var err error
+	files := make(map[string]bool)
+	if len(layout) == 0 {
+		t, subMods, err = engine.getDeep(file, "", t, files)
+	} else {
+		t, subMods, err = engine.parseLayout(t, file, layout, files)
+	}
+	if err != nil {
+		return &tplCache{err: err}
+	}
+	t, err = engine.getLoop(t, subMods, files, others...)
 	if err != nil {
-		return &tplCache{nil, err}
+		return &tplCache{err: err}
 	}
-	t, err = engine.getLoop(t, subMods, others...)
+	// the parsing returns the last parsed template, so the view template is looked up by the file name
+	t = t.Lookup(file)
+	tpl, err := t.Clone()
 	if err != nil {
-		return &tplCache{nil, err}
+		return &tplCache{err: err}
+	}
+	cache := &tplCache{tpl: tpl, src: t, layout: layout}
+	for f := range files {
+		cache.files = append(cache.files, f)
 	}
-	return &tplCache{t, nil}
+	return cache
 }
 
 func (engine *ViewEngine) compile() error {
@@ -161,38 +280,72 @@ func (engine *ViewEngine) compile() error {
 	if engine.compiled {
 		return nil
 	}
-	engine.AddFunc("include", engine.includeView)
-	if _, err := os.Stat(engine.viewDir); err != nil {
+	engine.AddFunc("include", engine.dialect.include(engine))
+	engine.AddFunc("layout", declareLayout)
+	if _, err := fs.Stat(engine.fsys, "."); err != nil {
 		if os.IsNotExist(err) {
 			return err
 		}
 		return fmt.Errorf("failed to open view directory '%s'", engine.viewDir)
 	}
 	vf := &file{
-		root:    engine.viewDir,
 		files:   make(map[string][]string),
 		viewExt: engine.viewExt,
 	}
-	err := filepath.Walk(engine.viewDir, func(path string, f os.FileInfo, err error) error {
-		return vf.visit(path, f, err)
-	})
+	err := fs.WalkDir(engine.fsys, ".", vf.visit)
 	if err != nil {
 		return err
 	}
+	viewStarts := make(map[string]string)
 	for _, v := range vf.files {
 		for _, file := range v {
-			v := engine.getTplCache(file, v...)
-			engine.addView(file, v)
+			layout, explicit, err := engine.explicitLayout(file)
+			if err != nil {
+				engine.addView(file, &tplCache{err: err})
+				continue
+			}
+			cache := engine.getTplCache(file, layout, v...)
+			if !explicit {
+				if layout = engine.defaultLayout(file, viewStarts); len(layout) > 0 {
+					page := engine.getTplCache(file, layout, v...)
+					page.partial = cache
+					cache = page
+				}
+			}
+			engine.addView(file, cache)
 		}
 	}
 	engine.compiled = true
 	return nil
 }
 
-func (engine *ViewEngine) includeView(viewName string, data interface{}) template.HTML {
-	str, err := engine.RenderStr(viewName, data)
+// includeView render the partial view for the view function 'include'
+func (engine *ViewEngine) includeView(viewName string, data interface{}) string {
+	buf := &bytes.Buffer{}
+	err := engine.RenderPartial(buf, viewName, data, nil)
 	assert.PanicErr(err)
-	return template.HTML(str)
+	return buf.String()
+}
+
+// ViewFiles get the absolute paths of the files that the view 'viewPath' is compiled from,
+// including the partial views referenced by the {{template}} actions
+func (engine *ViewEngine) ViewFiles(viewPath string) []string {
+	if !strings.HasSuffix(viewPath, engine.viewExt) {
+		viewPath = viewPath + engine.viewExt
+	}
+	if !engine.compiled && engine.compile() != nil {
+		return nil
+	}
+	engine.locker.RLock()
+	defer engine.locker.RUnlock()
+	if engine.viewMap == nil {
+		return nil
+	}
+	cacheView := engine.viewMap[strings.TrimLeft(viewPath, "/")]
+	if cacheView == nil {
+		return nil
+	}
+	return cacheView.files
 }
 
 // Clear clear all the cache in the view engine. and re-compile the view files into memory at next call 'Render' or 'RenderStr'
@@ -202,6 +355,49 @@ func (engine *ViewEngine) Clear() {
 
 	engine.compiled = false
 	engine.viewMap = nil
+	// the dependent engines may have compiled the views of the current engine
+	for _, dependent := range engine.dependents {
+		dependent.Clear()
+	}
+}
+
+// SetLocations set the locations (the directories relative to the view root) where the views, the layouts and the partial
+// views are searched in order. The default locations are the view root and the 'shared' directory
+func (engine *ViewEngine) SetLocations(locations ...string) {
+	assert.Assert("locations", func() bool {
+		return len(locations) > 0
+	})
+	engine.locker.Lock()
+	defer engine.locker.Unlock()
+	engine.locations = nil
+	for _, location := range locations {
+		engine.locations = append(engine.locations, strings.Trim(filepath.ToSlash(location), "/"))
+	}
+	engine.compiled = false
+	engine.viewMap = nil
+}
+
+// SetFallback set the fallback engine. The views, the layouts and the partial views that are not found in the view
+// locations of the current engine are searched in the view locations of the fallback engine
+func (engine *ViewEngine) SetFallback(fallback *ViewEngine) {
+	assert.NotNil("fallback", fallback)
+	assert.Assert("fallback", func() bool {
+		return fallback.dialect == engine.dialect
+	})
+	for e := fallback; e != nil; e = e.fallback {
+		assert.Assert("fallback", func() bool {
+			return e != engine
+		})
+	}
+	engine.locker.Lock()
+	engine.fallback = fallback
+	engine.compiled = false
+	engine.viewMap = nil
+	engine.locker.Unlock()
+
+	fallback.locker.Lock()
+	defer fallback.locker.Unlock()
+	fallback.dependents = append(fallback.dependents, engine)
 }
 
 // Render render the view file with given data and then write the result to an io writer.
@@ -209,6 +405,22 @@ func (engine *ViewEngine) Clear() {
 // viewData: the view data
 // writer: the given io writer
 func (engine *ViewEngine) Render(writer io.Writer, viewPath string, viewData interface{}) error {
+	return engine.RenderFuncs(writer, viewPath, viewData, nil)
+}
+
+// RenderFuncs render the view file like Render, but the view functions in funcs override the
+// functions added by AddFunc for this rendering only. The overridden functions must be added by AddFunc first.
+func (engine *ViewEngine) RenderFuncs(writer io.Writer, viewPath string, viewData interface{}, funcs template.FuncMap) error {
+	return engine.render(writer, viewPath, viewData, funcs, false)
+}
+
+// RenderPartial render the view file as a partial view. The default layout declared by '_viewstart' or SetLayout is
+// not applied to the partial view, but the layout declared by the view itself is still applied.
+func (engine *ViewEngine) RenderPartial(writer io.Writer, viewPath string, viewData interface{}, funcs template.FuncMap) error {
+	return engine.render(writer, viewPath, viewData, funcs, true)
+}
+
+func (engine *ViewEngine) render(writer io.Writer, viewPath string, viewData interface{}, funcs template.FuncMap, partial bool) error {
 	if writer == nil {
 		return errors.New("invalid writer")
 	}
@@ -224,12 +436,12 @@ func (engine *ViewEngine) Render(writer io.Writer, viewPath string, viewData int
 			return err
 		}
 	}
-	tpl, err := engine.getView(viewPath)
+	tpl, err := engine.getView(viewPath, funcs, partial)
 	if err != nil {
 		return err
 	}
 	if tpl == nil {
-		return fmt.Errorf("the view file '%s' cannot be found", viewPath)
+		return &NotFoundError{Name: viewPath, Searched: engine.searchPaths(strings.TrimLeft(viewPath, "/"))}
 	}
 	buf := &bytes.Buffer{}
 	err = tpl.Execute(buf, viewData)
@@ -253,35 +465,78 @@ func (engine *ViewEngine) RenderStr(viewPath string, viewData interface{}) (stri
 	return buf.String(), nil
 }
 
+// NotFoundError the error returned when the view cannot be found in any of the searched paths
+type NotFoundError struct {
+	Name     string
+	Searched []string
+}
+
+func (err *NotFoundError) Error() string {
+	return fmt.Sprintf("the view '%s' cannot be found, searched paths: %s", err.Name, strings.Join(err.Searched, ", "))
+}
+
 // NewEngine create a new view engine.
 // rootDir: the root dir of the view files;
 // ext: the view file extension(starts with "."), and the default file extension is '.gohtml';
 func NewEngine(rootDir, ext string) (*ViewEngine, error) {
-	if len(ext) == 0 {
-		ext = ".gohtml"
+	return newDiskEngine(rootDir, ext, htmlDialect)
+}
+
+// NewEngineFS create a new view engine that reads the view files from the file system, for example the embed.FS.
+// The view files are not watched, so the views are compiled only once.
+// fsys: the file system whose root is the root of the view files;
+// ext: the view file extension(starts with "."), and the default file extension is '.gohtml';
+func NewEngineFS(fsys fs.FS, ext string) (*ViewEngine, error) {
+	return newFSEngine(fsys, ext, htmlDialect)
+}
+
+// newDiskEngine create the engine of the dialect that reads and watches the view files in rootDir
+func newDiskEngine(rootDir, ext string, d *dialect) (*ViewEngine, error) {
+	rootDir = strings.Replace(path.Clean(rootDir), "\\", "/", -1) + "/"
+	stat, err := os.Stat(rootDir)
+	if err != nil {
+		return nil, err
 	}
-	if !strings.HasPrefix(ext, ".") {
-		ext = "." + ext
+	if !stat.IsDir() {
+		return nil, fmt.Errorf("failed to open the view directory '%s'", rootDir)
 	}
 	w, err := fswatcher.NewWatcher()
 	if err != nil {
 		return nil, err
 	}
-	rootDir = strings.Replace(path.Clean(rootDir), "\\", "/", -1) + "/"
-	engine := &ViewEngine{
-		viewDir: rootDir,
-		viewExt: strings.ToLower(ext),
-		watcher: w,
-	}
-	stat, err := os.Stat(engine.viewDir)
+	engine := newEngine(os.DirFS(rootDir), ext, d)
+	engine.viewDir = rootDir
+	engine.watcher = w
+	engine.watcher.AddHandler(&compileHandler{dir: rootDir, ext: engine.viewExt, watcher: w, clear: engine.Clear})
+	engine.watcher.Start()
+	engine.watcher.AddWatch(engine.viewDir, true)
+	return engine, nil
+}
+
+// newFSEngine create the engine of the dialect that reads the view files from the file system
+func newFSEngine(fsys fs.FS, ext string, d *dialect) (*ViewEngine, error) {
+	assert.NotNil("fsys", fsys)
+	stat, err := fs.Stat(fsys, ".")
 	if err != nil {
 		return nil, err
 	}
 	if !stat.IsDir() {
-		return nil, fmt.Errorf("failed to open the view directory '%s'", engine.viewDir)
+		return nil, errors.New("failed to open the root directory of the view file system")
 	}
-	engine.watcher.AddHandler(&compileHandler{engine})
-	engine.watcher.Start()
-	engine.watcher.AddWatch(engine.viewDir, true)
-	return engine, nil
-}
\ No newline at end of file
+	return newEngine(fsys, ext, d), nil
+}
+
+func newEngine(fsys fs.FS, ext string, d *dialect) *ViewEngine {
+	if len(ext) == 0 {
+		ext = d.ext
+	}
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return &ViewEngine{
+		fsys:      fsys,
+		viewExt:   strings.ToLower(ext),
+		locations: []string{"", "shared"},
+		dialect:   d,
+	}
+}
@@ -1,43 +1,46 @@
 package views
 
 import (
-	"html/template"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"path"
 	"strings"
 )
 
 type tplCache struct {
-	tpl *template.Template
-	err error
+	tpl   viewTemplate
+	src   viewTemplate
+	err   error
+	files []string
+	// layout the layout that the view is rendered in
+	layout string
+	// partial the view compiled without the default layout
+	partial *tplCache
 }
 
 type file struct {
 	viewExt string
-	root    string
 	files   map[string][]string
 }
 
-func (vf *file) visit(paths string, f os.FileInfo, err error) error {
-	if f == nil {
+func (vf *file) visit(p string, d fs.DirEntry, err error) error {
+	if d == nil {
 		return err
 	}
-	if f.IsDir() || (f.Mode()&os.ModeSymlink) > 0 {
+	if d.IsDir() || (d.Type()&fs.ModeSymlink) > 0 {
 		return nil
 	}
-	if !strings.HasSuffix(strings.ToLower(paths), vf.viewExt) {
+	if !strings.HasSuffix(strings.ToLower(p), vf.viewExt) {
 		return nil
 	}
-	replace := strings.NewReplacer("\\", "/")
-	a := []byte(paths)
-	a = a[len(vf.root):]
-	file := strings.TrimLeft(replace.Replace(string(a)), "/")
-	subDir := filepath.Dir(file)
+	if strings.EqualFold(strings.TrimSuffix(path.Base(p), path.Ext(p)), viewStartName) {
+		return nil
+	}
+	subDir := path.Dir(p)
 	if _, ok := vf.files[subDir]; ok {
-		vf.files[subDir] = append(vf.files[subDir], file)
+		vf.files[subDir] = append(vf.files[subDir], p)
 	} else {
 		m := make([]string, 1)
-		m[0] = file
+		m[0] = p
 		vf.files[subDir] = m
 	}
 	return nil
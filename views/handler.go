@@ -2,29 +2,33 @@ package views
 
 import (
 	"github.com/fsnotify/fsnotify"
+	"github.com/simbory/mego/fswatcher"
 	"os"
 	"path"
 	"strings"
 )
 
 type compileHandler struct {
-	engine *ViewEngine
+	dir     string
+	ext     string
+	watcher *fswatcher.FileWatcher
+	clear   func()
 }
 
 func (vh *compileHandler) CanHandle(path string) bool {
-	return strings.HasPrefix(path, vh.engine.viewDir) && strings.HasSuffix(strings.ToLower(path), strings.ToLower(vh.engine.viewExt))
+	return strings.HasPrefix(path, vh.dir) && strings.HasSuffix(strings.ToLower(path), strings.ToLower(vh.ext))
 }
 
 func (vh *compileHandler) Handle(ev *fsnotify.Event) {
 	strFile := strings.ToLower(path.Clean(ev.Name))
 	if ev.Op&fsnotify.Remove == fsnotify.Remove {
-		if !strings.HasSuffix(strFile, vh.engine.viewExt) {
-			vh.engine.watcher.RemoveWatch(ev.Name)
+		if !strings.HasSuffix(strFile, vh.ext) {
+			vh.watcher.RemoveWatch(ev.Name)
 		}
 	} else {
 		if state, err := os.Stat(ev.Name); err == nil && state.IsDir() {
-			vh.engine.watcher.AddWatch(ev.Name, true)
+			vh.watcher.AddWatch(ev.Name, true)
 		}
 	}
-	vh.engine.Clear()
+	vh.clear()
 }
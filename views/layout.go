@@ -0,0 +1,143 @@
+package views
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// layoutReg the regex of the layout declaration '{{layout "shared/_layout.gohtml"}}'
+var layoutReg = regexp.MustCompile("[{]{2}-?[ \\t]*layout[ \\t]+\"([^\"]*)\"[ \\t]*-?[}]{2}")
+
+// viewStartName the name of the file that declares the default layout of the views in the same directory
+// and the sub directories
+const viewStartName = "_viewstart"
+
+// declareLayout the view function 'layout'. The layout declaration is handled when the view is compiled,
+// so the function outputs nothing
+func declareLayout(name string) string {
+	return ""
+}
+
+// findLayout find the layout declaration in the view content
+func findLayout(data string) (string, bool) {
+	m := layoutReg.FindStringSubmatch(data)
+	if len(m) != 2 {
+		return "", false
+	}
+	return m[1], true
+}
+
+// layoutName get the layout path relative to the view root. The relative layout path ('./' or '../') is relative to
+// the file that declares the layout, and the layout path without the view extension is completed
+func (engine *ViewEngine) layoutName(layout, declaredIn string) string {
+	if len(layout) == 0 {
+		return ""
+	}
+	if !strings.HasSuffix(strings.ToLower(layout), engine.viewExt) {
+		layout = layout + engine.viewExt
+	}
+	if strings.HasPrefix(layout, "../") || strings.HasPrefix(layout, "./") {
+		layout = path.Join(path.Dir(declaredIn), layout)
+	}
+	return strings.TrimLeft(path.Clean(layout), "/")
+}
+
+// explicitLayout get the layout declared by the view itself
+func (engine *ViewEngine) explicitLayout(file string) (string, bool, error) {
+	data, err := engine.readView(file, "", nil)
+	if err != nil {
+		return "", false, err
+	}
+	layout, ok := findLayout(data)
+	if !ok {
+		return "", false, nil
+	}
+	return engine.layoutName(layout, file), true, nil
+}
+
+// defaultLayout get the default layout of the view. The default layout is declared by the nearest '_viewstart' file
+// in the view directory or the parent directories, otherwise it's the layout set by SetLayout. The views whose name
+// starts with '_' (the layouts and the partial views) have no default layout
+func (engine *ViewEngine) defaultLayout(file string, viewStarts map[string]string) string {
+	if strings.HasPrefix(path.Base(file), "_") {
+		return ""
+	}
+	dir := path.Dir(file)
+	for {
+		layout, ok := viewStarts[dir]
+		if !ok {
+			layout, ok = engine.viewStartLayout(dir)
+			if !ok {
+				layout = "\x00"
+			}
+			viewStarts[dir] = layout
+		}
+		if layout != "\x00" {
+			if layout == file {
+				return ""
+			}
+			return layout
+		}
+		if dir == "." || dir == "/" {
+			break
+		}
+		dir = path.Dir(dir)
+	}
+	if engine.layout == file {
+		return ""
+	}
+	return engine.layout
+}
+
+// viewStartLayout get the layout declared by the '_viewstart' file in the directory
+func (engine *ViewEngine) viewStartLayout(dir string) (string, bool) {
+	viewStart := path.Join(dir, viewStartName+engine.viewExt)
+	data, err := engine.readFile(viewStart, nil)
+	if err != nil {
+		return "", false
+	}
+	layout, ok := findLayout(data)
+	if !ok {
+		return "", false
+	}
+	return engine.layoutName(layout, viewStart), true
+}
+
+// parseLayout parse the layout as the template of the view, and then parse the view as the template 'body'.
+// The layout is parsed first, so the sections defined by the view override the default content of the {{block}} actions
+func (engine *ViewEngine) parseLayout(t viewTemplate, file, layout string, files map[string]bool) (viewTemplate, [][]string, error) {
+	layoutData, err := engine.readView(layout, "", files)
+	if nf, ok := err.(*NotFoundError); ok {
+		return nil, [][]string{}, fmt.Errorf("the layout '%s' of the view '%s' cannot be found, searched paths: %s",
+			layout, file, strings.Join(nf.Searched, ", "))
+	}
+	if err != nil {
+		return nil, [][]string{}, err
+	}
+	t, layoutSubs, err := engine.parseView(t, file, layout, layoutData, files)
+	if err != nil {
+		return nil, [][]string{}, err
+	}
+	viewData, err := engine.readView(file, "", files)
+	if err != nil {
+		return nil, [][]string{}, err
+	}
+	t, viewSubs, err := engine.parseView(t, "body", file, viewData, files)
+	if err != nil {
+		return nil, [][]string{}, err
+	}
+	return t, append(layoutSubs, viewSubs...), nil
+}
+
+// SetLayout set the default layout of the views that don't declare the layout and aren't covered by a '_viewstart' file.
+// The layout renders the view by {{template "body" .}} and the optional sections by {{block "name" .}}{{end}}
+func (engine *ViewEngine) SetLayout(layout string) {
+	engine.locker.Lock()
+	defer engine.locker.Unlock()
+	engine.layout = engine.layoutName(filepath.ToSlash(layout), "")
+	engine.compiled = false
+	engine.viewMap = nil
+}
@@ -0,0 +1,177 @@
+package views
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template/parse"
+)
+
+// ViewError the error of the view template
+type ViewError struct {
+	// View the view that the error is found in
+	View string
+	// File the view file that the error is located in, it's empty if the location is unknown
+	File string
+	// Line the line number of the error in the file, it's 0 if the location is unknown
+	Line int
+	// Err the original error
+	Err error
+}
+
+func (err *ViewError) Error() string {
+	if len(err.File) == 0 {
+		return fmt.Sprintf("%s: %s", err.View, err.Err.Error())
+	}
+	if err.File == err.View {
+		return fmt.Sprintf("%s:%d: %s", err.File, err.Line, err.Err.Error())
+	}
+	return fmt.Sprintf("%s:%d: %s (in view '%s')", err.File, err.Line, err.Err.Error(), err.View)
+}
+
+// CompileError the error returned by Precompile, it contains the errors of all the broken views
+type CompileError struct {
+	Errors []*ViewError
+}
+
+func (err *CompileError) Error() string {
+	lines := make([]string, 0, len(err.Errors)+1)
+	lines = append(lines, fmt.Sprintf("%d view error(s) found:", len(err.Errors)))
+	for _, e := range err.Errors {
+		lines = append(lines, "    "+e.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// templateErrReg the regex of the template error location 'template: name:line:'
+var templateErrReg = regexp.MustCompile(`^(?:html/)?template: ?([^:]+):(\d+):(?:\d+:)? ?`)
+
+// newViewError create the view error and get the location of the error from the template error message
+func newViewError(view string, err error) *ViewError {
+	ve := &ViewError{View: view, Err: err}
+	if m := templateErrReg.FindStringSubmatch(err.Error()); len(m) == 3 {
+		ve.File = m[1]
+		ve.Line, _ = strconv.Atoi(m[2])
+		ve.Err = fmt.Errorf("%s", strings.TrimPrefix(err.Error(), m[0]))
+	}
+	return ve
+}
+
+// checkRefs check if all the templates referenced by the {{template}} actions are defined
+func checkRefs(view string, t viewTemplate) []*ViewError {
+	var errs []*ViewError
+	for _, tpl := range t.Templates() {
+		tree := tpl.Tree()
+		if tree == nil || tree.Root == nil {
+			continue
+		}
+		walkTemplateNodes(tree.Root, func(node *parse.TemplateNode) {
+			if ref := t.Lookup(node.Name); ref != nil && ref.Tree() != nil {
+				return
+			}
+			location, _ := tree.ErrorContext(node)
+			err := fmt.Errorf("template: %s: the template '%s' is not defined", location, node.Name)
+			errs = append(errs, newViewError(view, err))
+		})
+	}
+	return errs
+}
+
+// walkTemplateNodes call f for each {{template}} action in the node
+func walkTemplateNodes(node parse.Node, f func(node *parse.TemplateNode)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, sub := range n.Nodes {
+			walkTemplateNodes(sub, f)
+		}
+	case *parse.IfNode:
+		walkTemplateNodes(n.List, f)
+		walkTemplateNodes(n.ElseList, f)
+	case *parse.RangeNode:
+		walkTemplateNodes(n.List, f)
+		walkTemplateNodes(n.ElseList, f)
+	case *parse.WithNode:
+		walkTemplateNodes(n.List, f)
+		walkTemplateNodes(n.ElseList, f)
+	case *parse.TemplateNode:
+		f(n)
+	}
+}
+
+// includedViews get the layouts and the partial views that are included by the other views. They are checked
+// the way they are rendered, as the parts of the views that include them
+func (engine *ViewEngine) includedViews() map[string]bool {
+	included := make(map[string]bool)
+	if len(engine.layout) > 0 {
+		included[engine.layout] = true
+	}
+	for name, cache := range engine.viewMap {
+		for _, c := range []*tplCache{cache, cache.partial} {
+			if c == nil || c.src == nil {
+				continue
+			}
+			if len(c.layout) > 0 {
+				included[c.layout] = true
+			}
+			for _, tpl := range c.src.Templates() {
+				partial := strings.TrimLeft(path.Clean(tpl.Name()), "/")
+				if partial != name && strings.HasSuffix(strings.ToLower(partial), engine.viewExt) {
+					included[partial] = true
+				}
+			}
+		}
+	}
+	return included
+}
+
+// Precompile compile all the views in the view root and check the templates referenced by the {{template}} actions,
+// so that the broken views are found before they are requested. The layouts and the partial views are checked as
+// the parts of the views that include them. All the errors are returned in one CompileError
+func (engine *ViewEngine) Precompile() error {
+	if err := engine.compile(); err != nil {
+		return err
+	}
+	engine.locker.RLock()
+	defer engine.locker.RUnlock()
+	included := engine.includedViews()
+	names := make([]string, 0, len(engine.viewMap))
+	for name := range engine.viewMap {
+		if !included[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var errs []*ViewError
+	found := make(map[string]bool)
+	for _, name := range names {
+		cache := engine.viewMap[name]
+		for _, c := range []*tplCache{cache, cache.partial} {
+			if c == nil {
+				continue
+			}
+			var viewErrs []*ViewError
+			if c.err != nil {
+				viewErrs = []*ViewError{newViewError(name, c.err)}
+			} else {
+				viewErrs = checkRefs(name, c.src)
+			}
+			// the page and the partial variants of the view usually have the same errors
+			for _, e := range viewErrs {
+				if msg := e.Error(); !found[msg] {
+					found[msg] = true
+					errs = append(errs, e)
+				}
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &CompileError{Errors: errs}
+}
@@ -0,0 +1,108 @@
+package views
+
+import (
+	"html/template"
+	"io"
+	"text/template/parse"
+)
+
+// viewTemplate the template set of html/template or text/template that the views are compiled to
+type viewTemplate interface {
+	// Name get the name of the template
+	Name() string
+	// New allocate a new template with the name that is associated with the template set
+	New(name string) viewTemplate
+	// Parse parse the text as the template body
+	Parse(text string) (viewTemplate, error)
+	// Lookup get the associated template with the name. It returns nil if the template is not found
+	Lookup(name string) viewTemplate
+	// Templates get all the associated templates
+	Templates() []viewTemplate
+	// Tree get the parse tree of the template
+	Tree() *parse.Tree
+	// Funcs add the functions to the function map of the template set
+	Funcs(funcs map[string]interface{}) viewTemplate
+	// Clone get the copy of the template set
+	Clone() (viewTemplate, error)
+	// Execute apply the template to the data and write the output to w
+	Execute(w io.Writer, data interface{}) error
+}
+
+// dialect the template package that the views of the engine are compiled by
+type dialect struct {
+	// ext the default file extension of the views
+	ext string
+	// newTemplate create the template set
+	newTemplate func(name string) viewTemplate
+	// include get the view function 'include' of the engine that renders the partial view
+	include func(engine *ViewEngine) interface{}
+}
+
+// htmlDialect the default dialect based on html/template
+var htmlDialect = &dialect{
+	ext: ".gohtml",
+	newTemplate: func(name string) viewTemplate {
+		return &htmlTemplate{t: template.New(name)}
+	},
+	include: func(engine *ViewEngine) interface{} {
+		return func(viewName string, data interface{}) template.HTML {
+			return template.HTML(engine.includeView(viewName, data))
+		}
+	},
+}
+
+// htmlTemplate the template of html/template
+type htmlTemplate struct {
+	t *template.Template
+}
+
+func (ht *htmlTemplate) Name() string {
+	return ht.t.Name()
+}
+
+func (ht *htmlTemplate) New(name string) viewTemplate {
+	return &htmlTemplate{t: ht.t.New(name)}
+}
+
+func (ht *htmlTemplate) Parse(text string) (viewTemplate, error) {
+	t, err := ht.t.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &htmlTemplate{t: t}, nil
+}
+
+func (ht *htmlTemplate) Lookup(name string) viewTemplate {
+	if t := ht.t.Lookup(name); t != nil {
+		return &htmlTemplate{t: t}
+	}
+	return nil
+}
+
+func (ht *htmlTemplate) Templates() []viewTemplate {
+	var templates []viewTemplate
+	for _, t := range ht.t.Templates() {
+		templates = append(templates, &htmlTemplate{t: t})
+	}
+	return templates
+}
+
+func (ht *htmlTemplate) Tree() *parse.Tree {
+	return ht.t.Tree
+}
+
+func (ht *htmlTemplate) Funcs(funcs map[string]interface{}) viewTemplate {
+	return &htmlTemplate{t: ht.t.Funcs(funcs)}
+}
+
+func (ht *htmlTemplate) Clone() (viewTemplate, error) {
+	t, err := ht.t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return &htmlTemplate{t: t}, nil
+}
+
+func (ht *htmlTemplate) Execute(w io.Writer, data interface{}) error {
+	return ht.t.Execute(w, data)
+}
@@ -0,0 +1,103 @@
+package views
+
+import (
+	"io"
+	"io/fs"
+	"text/template"
+	"text/template/parse"
+)
+
+// textDialect the dialect based on text/template for the non-HTML output
+var textDialect = &dialect{
+	ext: ".gotxt",
+	newTemplate: func(name string) viewTemplate {
+		return &textTemplate{t: template.New(name)}
+	},
+	include: func(engine *ViewEngine) interface{} {
+		return engine.includeView
+	},
+}
+
+// textTemplate the template of text/template
+type textTemplate struct {
+	t *template.Template
+}
+
+func (tt *textTemplate) Name() string {
+	return tt.t.Name()
+}
+
+func (tt *textTemplate) New(name string) viewTemplate {
+	return &textTemplate{t: tt.t.New(name)}
+}
+
+func (tt *textTemplate) Parse(text string) (viewTemplate, error) {
+	t, err := tt.t.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &textTemplate{t: t}, nil
+}
+
+func (tt *textTemplate) Lookup(name string) viewTemplate {
+	if t := tt.t.Lookup(name); t != nil {
+		return &textTemplate{t: t}
+	}
+	return nil
+}
+
+func (tt *textTemplate) Templates() []viewTemplate {
+	var templates []viewTemplate
+	for _, t := range tt.t.Templates() {
+		templates = append(templates, &textTemplate{t: t})
+	}
+	return templates
+}
+
+func (tt *textTemplate) Tree() *parse.Tree {
+	return tt.t.Tree
+}
+
+func (tt *textTemplate) Funcs(funcs map[string]interface{}) viewTemplate {
+	return &textTemplate{t: tt.t.Funcs(funcs)}
+}
+
+func (tt *textTemplate) Clone() (viewTemplate, error) {
+	t, err := tt.t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return &textTemplate{t: t}, nil
+}
+
+func (tt *textTemplate) Execute(w io.Writer, data interface{}) error {
+	return tt.t.Execute(w, data)
+}
+
+// TextEngine the view engine based on text/template for the non-HTML output, for example the plain text emails.
+// The views are looked up, compiled and rendered with the layouts and the partial views like ViewEngine, but the
+// output is not escaped
+type TextEngine struct {
+	*ViewEngine
+}
+
+// NewTextEngine create a new text view engine.
+// rootDir: the root dir of the view files;
+// ext: the view file extension(starts with "."), and the default file extension is '.gotxt';
+func NewTextEngine(rootDir, ext string) (*TextEngine, error) {
+	engine, err := newDiskEngine(rootDir, ext, textDialect)
+	if err != nil {
+		return nil, err
+	}
+	return &TextEngine{ViewEngine: engine}, nil
+}
+
+// NewTextEngineFS create a new text view engine that reads the view files from the file system.
+// The view files are not watched, so the views are compiled only once.
+func NewTextEngineFS(fsys fs.FS, ext string) (*TextEngine, error) {
+	engine, err := newFSEngine(fsys, ext, textDialect)
+	if err != nil {
+		return nil, err
+	}
+	return &TextEngine{ViewEngine: engine}, nil
+}
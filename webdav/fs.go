@@ -0,0 +1,113 @@
+package webdav
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// File the file opened by the FileSystem
+type File interface {
+	io.Reader
+	io.Writer
+	io.Seeker
+	io.Closer
+	// Readdir read the entries of the directory
+	Readdir(count int) ([]os.FileInfo, error)
+	// Stat get the file info
+	Stat() (os.FileInfo, error)
+}
+
+// FileSystem the backend of the WebDAV handler. The names are slash-separated paths that start with '/'
+type FileSystem interface {
+	// Stat get the file info of the name
+	Stat(name string) (os.FileInfo, error)
+	// OpenFile open the file with the flags of os.OpenFile
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	// Mkdir create the directory
+	Mkdir(name string, perm os.FileMode) error
+	// RemoveAll remove the file or the directory and all its children
+	RemoveAll(name string) error
+	// Rename rename the file or the directory
+	Rename(oldName, newName string) error
+}
+
+// errInvalidName the error of the name that is not a clean slash-separated path
+var errInvalidName = errors.New("webdav: invalid file name")
+
+// Dir the FileSystem that is backed by the directory on the disk
+type Dir string
+
+// resolve get the os path of the name
+func (d Dir) resolve(name string) (string, error) {
+	if strings.ContainsRune(name, '\x00') || (filepath.Separator != '/' && strings.ContainsRune(name, filepath.Separator)) {
+		return "", errInvalidName
+	}
+	dir := string(d)
+	if len(dir) == 0 {
+		dir = "."
+	}
+	return filepath.Join(dir, filepath.FromSlash(path.Clean("/"+name))), nil
+}
+
+// Stat get the file info of the name
+func (d Dir) Stat(name string) (os.FileInfo, error) {
+	p, err := d.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(p)
+}
+
+// OpenFile open the file with the flags of os.OpenFile
+func (d Dir) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	p, err := d.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(p, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Mkdir create the directory
+func (d Dir) Mkdir(name string, perm os.FileMode) error {
+	p, err := d.resolve(name)
+	if err != nil {
+		return err
+	}
+	return os.Mkdir(p, perm)
+}
+
+// RemoveAll remove the file or the directory and all its children. The root directory cannot be removed
+func (d Dir) RemoveAll(name string) error {
+	p, err := d.resolve(name)
+	if err != nil {
+		return err
+	}
+	if path.Clean("/"+name) == "/" {
+		return os.ErrInvalid
+	}
+	return os.RemoveAll(p)
+}
+
+// Rename rename the file or the directory. The root directory cannot be renamed
+func (d Dir) Rename(oldName, newName string) error {
+	oldPath, err := d.resolve(oldName)
+	if err != nil {
+		return err
+	}
+	newPath, err := d.resolve(newName)
+	if err != nil {
+		return err
+	}
+	if path.Clean("/"+oldName) == "/" || path.Clean("/"+newName) == "/" {
+		return os.ErrInvalid
+	}
+	return os.Rename(oldPath, newPath)
+}
@@ -0,0 +1,177 @@
+package webdav
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lock the WebDAV lock of the resource
+type Lock struct {
+	// Token the lock token, for example 'urn:uuid:...'
+	Token string
+	// Root the locked resource
+	Root string
+	// Infinite the lock applies to all the members of the collection
+	Infinite bool
+	// Exclusive the lock is exclusive, otherwise it's shared
+	Exclusive bool
+	// Owner the XML content of the 'owner' element
+	Owner string
+	// Timeout the lock timeout, 0 means infinite
+	Timeout time.Duration
+	expires time.Time
+}
+
+// expired check if the lock is expired
+func (l *Lock) expired(now time.Time) bool {
+	return l.Timeout > 0 && now.After(l.expires)
+}
+
+// covers check if the lock applies to the resource
+func (l *Lock) covers(name string) bool {
+	return l.Root == name || (l.Infinite && isDescendant(name, l.Root))
+}
+
+// isDescendant check if the name is under the directory dir
+func isDescendant(name, dir string) bool {
+	if dir == "/" {
+		return name != "/"
+	}
+	return strings.HasPrefix(name, dir+"/")
+}
+
+// LockSystem the in-memory lock manager of the WebDAV handler. The expired locks are removed when the locks are accessed
+type LockSystem struct {
+	lock  sync.Mutex
+	locks map[string]*Lock
+}
+
+// NewLockSystem create the in-memory lock manager
+func NewLockSystem() *LockSystem {
+	return &LockSystem{locks: make(map[string]*Lock)}
+}
+
+// purge remove the expired locks, the caller must hold the lock
+func (ls *LockSystem) purge() {
+	now := time.Now()
+	for token, l := range ls.locks {
+		if l.expired(now) {
+			delete(ls.locks, token)
+		}
+	}
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	h := hex.EncodeToString(b)
+	return "urn:uuid:" + h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// Create create the lock. It returns nil if the lock conflicts with the existing locks
+func (ls *LockSystem) Create(l Lock) *Lock {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	ls.purge()
+	for _, other := range ls.locks {
+		overlapped := other.covers(l.Root) || (l.Infinite && isDescendant(other.Root, l.Root))
+		if overlapped && (other.Exclusive || l.Exclusive) {
+			return nil
+		}
+	}
+	created := l
+	created.Token = newToken()
+	created.expires = time.Now().Add(created.Timeout)
+	ls.locks[created.Token] = &created
+	result := created
+	return &result
+}
+
+// Refresh reset the timeout of the lock
+func (ls *LockSystem) Refresh(token string, timeout time.Duration) *Lock {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	ls.purge()
+	l, ok := ls.locks[token]
+	if !ok {
+		return nil
+	}
+	l.Timeout = timeout
+	l.expires = time.Now().Add(timeout)
+	result := *l
+	return &result
+}
+
+// Unlock remove the lock of the token that applies to the resource. It returns false if there is no such lock
+func (ls *LockSystem) Unlock(name, token string) bool {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	ls.purge()
+	l, ok := ls.locks[token]
+	if !ok || !l.covers(name) {
+		return false
+	}
+	delete(ls.locks, token)
+	return true
+}
+
+// Discover get the locks that apply to the resource
+func (ls *LockSystem) Discover(name string) []*Lock {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	ls.purge()
+	var locks []*Lock
+	for _, l := range ls.locks {
+		if l.covers(name) {
+			result := *l
+			locks = append(locks, &result)
+		}
+	}
+	return locks
+}
+
+// Confirm check if the tokens submitted by the client unlock the resource. If recursive is true, the locks of the
+// members of the collection are also checked. One of the shared locks of the same resource is enough
+func (ls *LockSystem) Confirm(name string, recursive bool, tokens []string) bool {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	ls.purge()
+	satisfied := make(map[string]bool)
+	for _, l := range ls.locks {
+		if !l.covers(name) && !(recursive && isDescendant(l.Root, name)) {
+			continue
+		}
+		found := satisfied[l.Root]
+		for _, token := range tokens {
+			if token == l.Token {
+				found = true
+				break
+			}
+		}
+		satisfied[l.Root] = found
+	}
+	for _, ok := range satisfied {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// remove remove the locks of the resource and its members, it's called after the resource is deleted or moved away
+func (ls *LockSystem) remove(name string) {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	for token, l := range ls.locks {
+		if l.Root == name || isDescendant(l.Root, name) {
+			delete(ls.locks, token)
+		}
+	}
+}
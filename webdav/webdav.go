@@ -0,0 +1,797 @@
+// Package webdav implements the WebDAV server (RFC 4918, class 1 and 2) that is mounted on the mego route.
+//
+// The handler is registered on the route that ends with the '*pathInfo' parameter. The '*pathInfo' parameter
+// does not match the empty path, so the mount point itself is also registered, for example:
+//
+//	dav := webdav.New(webdav.Dir("/data/dav"), nil)
+//	server.Route("/dav", dav)
+//	server.Route("/dav/*pathInfo", dav)
+//
+// The handler is processed like the other routes, so the hijacks of the route (for example the authentication)
+// are executed before the WebDAV methods.
+package webdav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simbory/mego"
+	"github.com/simbory/mego/assert"
+)
+
+const (
+	// defaultTimeout the lock timeout if the client does not specify the 'Timeout' header
+	defaultTimeout = time.Hour
+	// maxTimeout the max lock timeout, the infinite locks are also limited by it
+	maxTimeout = 24 * time.Hour
+	// infiniteDepth the value of the infinite 'Depth' header
+	infiniteDepth = -1
+)
+
+// Handler the WebDAV handler that serves the files of the FileSystem
+type Handler struct {
+	fs        FileSystem
+	locks     *LockSystem
+	propsLock sync.RWMutex
+	// props the dead properties of the resources, they are kept in the memory
+	props map[string]map[xml.Name]string
+}
+
+// New create the WebDAV handler of the file system. If locks is nil, a new in-memory lock manager is used
+func New(fs FileSystem, locks *LockSystem) *Handler {
+	assert.NotNil("fs", fs)
+	if locks == nil {
+		locks = NewLockSystem()
+	}
+	return &Handler{fs: fs, locks: locks, props: make(map[string]map[xml.Name]string)}
+}
+
+// davResult the result that executes the WebDAV method
+type davResult struct {
+	h    *Handler
+	name string
+}
+
+// ExecResult execute the WebDAV method
+func (dr *davResult) ExecResult(w http.ResponseWriter, r *http.Request) {
+	dr.h.serve(w, r, dr.name)
+}
+
+// ProcessRequest process the WebDAV request. The resource name is the route parameter 'pathInfo'
+func (h *Handler) ProcessRequest(ctx *mego.HttpCtx) interface{} {
+	return &davResult{h: h, name: path.Clean("/" + ctx.RouteVar("pathInfo"))}
+}
+
+// request the WebDAV request
+type request struct {
+	w      http.ResponseWriter
+	r      *http.Request
+	name   string
+	prefix string
+}
+
+// href get the url of the resource
+func (req *request) href(name string, isDir bool) string {
+	u := &url.URL{Path: req.prefix + name}
+	href := u.EscapedPath()
+	if isDir && !strings.HasSuffix(href, "/") {
+		href = href + "/"
+	}
+	return href
+}
+
+// status write the status code without the body
+func (req *request) status(code int) {
+	http.Error(req.w, http.StatusText(code), code)
+}
+
+// tokens get the lock tokens that are submitted in the 'If' header
+func (req *request) tokens() []string {
+	return ifTokens(req.r.Header.Get("If"))
+}
+
+// confirm check if the resource is unlocked by the submitted tokens, and write 423 Locked if it's not
+func (req *request) confirm(h *Handler, name string, recursive bool) bool {
+	if h.locks.Confirm(name, recursive, req.tokens()) {
+		return true
+	}
+	req.status(http.StatusLocked)
+	return false
+}
+
+// urlPrefix get the url prefix of the WebDAV mount from the request path and the resource name
+func urlPrefix(urlPath, name string) string {
+	p := path.Clean("/" + urlPath)
+	if name != "/" {
+		p = strings.TrimSuffix(p, name)
+	}
+	return strings.TrimRight(p, "/")
+}
+
+// depth parse the 'Depth' header, the default value is infinity. It returns -2 if the header is invalid
+func depth(header string, defaultValue int) int {
+	switch strings.ToLower(strings.TrimSpace(header)) {
+	case "":
+		return defaultValue
+	case "0":
+		return 0
+	case "1":
+		return 1
+	case "infinity":
+		return infiniteDepth
+	}
+	return -2
+}
+
+// lockTimeout parse the 'Timeout' header, for example 'Second-3600' or 'Infinite, Second-4100'
+func lockTimeout(header string) time.Duration {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "Infinite" {
+			return maxTimeout
+		}
+		if strings.HasPrefix(v, "Second-") {
+			n, err := strconv.ParseInt(v[len("Second-"):], 10, 64)
+			if err != nil || n <= 0 {
+				continue
+			}
+			timeout := time.Duration(n) * time.Second
+			if timeout > maxTimeout || timeout <= 0 {
+				timeout = maxTimeout
+			}
+			return timeout
+		}
+	}
+	return defaultTimeout
+}
+
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request, name string) {
+	req := &request{w: w, r: r, name: name, prefix: urlPrefix(r.URL.Path, name)}
+	switch r.Method {
+	case "OPTIONS":
+		h.options(req)
+	case "GET", "HEAD":
+		h.get(req)
+	case "PUT":
+		h.put(req)
+	case "DELETE":
+		h.delete(req)
+	case "MKCOL":
+		h.mkcol(req)
+	case "COPY", "MOVE":
+		h.copyMove(req)
+	case "PROPFIND":
+		h.propfind(req)
+	case "PROPPATCH":
+		h.proppatch(req)
+	case "LOCK":
+		h.lock(req)
+	case "UNLOCK":
+		h.unlock(req)
+	default:
+		req.status(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) options(req *request) {
+	allow := "OPTIONS, LOCK, PUT, MKCOL"
+	if info, err := h.fs.Stat(req.name); err == nil {
+		if info.IsDir() {
+			allow = "OPTIONS, LOCK, DELETE, PROPPATCH, COPY, MOVE, UNLOCK, PROPFIND"
+		} else {
+			allow = "OPTIONS, LOCK, GET, HEAD, POST, DELETE, PROPPATCH, COPY, MOVE, UNLOCK, PROPFIND, PUT"
+		}
+	}
+	req.w.Header().Set("Allow", allow)
+	req.w.Header().Set("DAV", "1, 2")
+	// the header is required by the Microsoft clients
+	req.w.Header().Set("MS-Author-Via", "DAV")
+	req.w.Header().Set("Content-Length", "0")
+	req.w.WriteHeader(http.StatusOK)
+}
+
+// etag get the entity tag of the file from the modification time and the size
+func etag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+func (h *Handler) get(req *request) {
+	f, err := h.fs.OpenFile(req.name, os.O_RDONLY, 0)
+	if err != nil {
+		req.status(statusOf(err))
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		req.status(statusOf(err))
+		return
+	}
+	if info.IsDir() {
+		req.status(http.StatusMethodNotAllowed)
+		return
+	}
+	req.w.Header().Set("ETag", etag(info))
+	http.ServeContent(req.w, req.r, info.Name(), info.ModTime(), f)
+}
+
+// statusOf get the status code of the file system error
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, os.ErrPermission), errors.Is(err, os.ErrInvalid), err == errInvalidName:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// parentExists check if the parent collection of the resource exists
+func (h *Handler) parentExists(name string) bool {
+	info, err := h.fs.Stat(path.Dir(name))
+	return err == nil && info.IsDir()
+}
+
+func (h *Handler) put(req *request) {
+	if !req.confirm(h, req.name, false) {
+		return
+	}
+	info, err := h.fs.Stat(req.name)
+	if err == nil && info.IsDir() {
+		req.status(http.StatusMethodNotAllowed)
+		return
+	}
+	created := err != nil
+	if created && !h.parentExists(req.name) {
+		req.status(http.StatusConflict)
+		return
+	}
+	f, err := h.fs.OpenFile(req.name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		req.status(statusOf(err))
+		return
+	}
+	_, copyErr := io.Copy(f, req.r.Body)
+	closeErr := f.Close()
+	if copyErr != nil || closeErr != nil {
+		req.status(http.StatusInternalServerError)
+		return
+	}
+	if info, err := h.fs.Stat(req.name); err == nil {
+		req.w.Header().Set("ETag", etag(info))
+	}
+	if created {
+		req.status(http.StatusCreated)
+		return
+	}
+	req.w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) delete(req *request) {
+	if req.name == "/" {
+		req.status(http.StatusForbidden)
+		return
+	}
+	if !req.confirm(h, req.name, true) {
+		return
+	}
+	if _, err := h.fs.Stat(req.name); err != nil {
+		req.status(statusOf(err))
+		return
+	}
+	if err := h.fs.RemoveAll(req.name); err != nil {
+		req.status(statusOf(err))
+		return
+	}
+	h.locks.remove(req.name)
+	h.moveProps(req.name, "", false)
+	req.w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) mkcol(req *request) {
+	if req.r.ContentLength > 0 {
+		req.status(http.StatusUnsupportedMediaType)
+		return
+	}
+	if !req.confirm(h, req.name, false) {
+		return
+	}
+	if _, err := h.fs.Stat(req.name); err == nil {
+		req.status(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.parentExists(req.name) {
+		req.status(http.StatusConflict)
+		return
+	}
+	if err := h.fs.Mkdir(req.name, 0777); err != nil {
+		req.status(statusOf(err))
+		return
+	}
+	req.status(http.StatusCreated)
+}
+
+// destination get the resource name of the 'Destination' header. It returns the status code if the destination
+// is invalid
+func (req *request) destination() (string, int) {
+	u, err := url.Parse(req.r.Header.Get("Destination"))
+	if err != nil || len(u.Path) == 0 {
+		return "", http.StatusBadRequest
+	}
+	if len(u.Host) > 0 && u.Host != req.r.Host {
+		return "", http.StatusBadGateway
+	}
+	p := path.Clean("/" + u.Path)
+	if len(req.prefix) > 0 {
+		if p != req.prefix && !strings.HasPrefix(p, req.prefix+"/") {
+			return "", http.StatusBadGateway
+		}
+		p = p[len(req.prefix):]
+	}
+	return path.Clean("/" + p), 0
+}
+
+func (h *Handler) copyMove(req *request) {
+	dst, status := req.destination()
+	if status != 0 {
+		req.status(status)
+		return
+	}
+	if dst == req.name || isDescendant(dst, req.name) || dst == "/" {
+		req.status(http.StatusForbidden)
+		return
+	}
+	info, err := h.fs.Stat(req.name)
+	if err != nil {
+		req.status(statusOf(err))
+		return
+	}
+	move := req.r.Method == "MOVE"
+	d := depth(req.r.Header.Get("Depth"), infiniteDepth)
+	if (move && d != infiniteDepth) || (!move && d != 0 && d != infiniteDepth) {
+		req.status(http.StatusBadRequest)
+		return
+	}
+	if move && !req.confirm(h, req.name, true) {
+		return
+	}
+	if !req.confirm(h, dst, true) {
+		return
+	}
+	if !h.parentExists(dst) {
+		req.status(http.StatusConflict)
+		return
+	}
+	_, err = h.fs.Stat(dst)
+	exists := err == nil
+	if exists {
+		if req.r.Header.Get("Overwrite") == "F" {
+			req.status(http.StatusPreconditionFailed)
+			return
+		}
+		if err := h.fs.RemoveAll(dst); err != nil {
+			req.status(statusOf(err))
+			return
+		}
+		h.locks.remove(dst)
+		h.moveProps(dst, "", false)
+	}
+	if move {
+		if err := h.fs.Rename(req.name, dst); err != nil {
+			req.status(statusOf(err))
+			return
+		}
+		h.locks.remove(req.name)
+		h.moveProps(req.name, dst, false)
+	} else {
+		if err := h.copyAll(req.name, dst, info, d == infiniteDepth); err != nil {
+			req.status(statusOf(err))
+			return
+		}
+		h.moveProps(req.name, dst, true)
+	}
+	if exists {
+		req.w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	req.status(http.StatusCreated)
+}
+
+// copyAll copy the file or the collection. If recursive is false, only the collection itself is copied
+func (h *Handler) copyAll(src, dst string, info os.FileInfo, recursive bool) error {
+	if info.IsDir() {
+		if err := h.fs.Mkdir(dst, info.Mode().Perm()|0700); err != nil {
+			return err
+		}
+		if !recursive {
+			return nil
+		}
+		children, err := h.readDir(src)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			err = h.copyAll(path.Join(src, child.Name()), path.Join(dst, child.Name()), child, true)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	in, err := h.fs.OpenFile(src, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := h.fs.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// readDir read the members of the collection sorted by the name
+func (h *Handler) readDir(name string) ([]os.FileInfo, error) {
+	f, err := h.fs.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	children, err := f.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Name() < children[j].Name()
+	})
+	return children, nil
+}
+
+// moveProps move or copy the dead properties of the resource and its members. If dst is empty, the properties
+// are removed
+func (h *Handler) moveProps(src, dst string, keep bool) {
+	h.propsLock.Lock()
+	defer h.propsLock.Unlock()
+	for name, props := range h.props {
+		if name != src && !isDescendant(name, src) {
+			continue
+		}
+		if len(dst) > 0 {
+			copied := make(map[xml.Name]string, len(props))
+			for k, v := range props {
+				copied[k] = v
+			}
+			h.props[dst+strings.TrimPrefix(name, src)] = copied
+		}
+		if !keep {
+			delete(h.props, name)
+		}
+	}
+}
+
+// liveProps get the live properties of the resource
+func (h *Handler) liveProps(req *request, name string, info os.FileInfo) []property {
+	davName := func(local string) xml.Name {
+		return xml.Name{Space: davNS, Local: local}
+	}
+	displayName := info.Name()
+	if name == "/" {
+		displayName = ""
+	}
+	props := []property{
+		{name: davName("displayname"), value: escape(displayName)},
+		{name: davName("getlastmodified"), value: info.ModTime().UTC().Format(http.TimeFormat)},
+	}
+	if info.IsDir() {
+		props = append(props, property{name: davName("resourcetype"), value: "<D:collection/>"})
+	} else {
+		contentType := mime.TypeByExtension(path.Ext(name))
+		if len(contentType) == 0 {
+			contentType = "application/octet-stream"
+		}
+		props = append(props,
+			property{name: davName("resourcetype")},
+			property{name: davName("getcontentlength"), value: strconv.FormatInt(info.Size(), 10)},
+			property{name: davName("getcontenttype"), value: escape(contentType)},
+			property{name: davName("getetag"), value: escape(etag(info))},
+		)
+	}
+	props = append(props, property{name: davName("supportedlock"), value: supportedLock})
+	discovery := &bytes.Buffer{}
+	for _, l := range h.locks.Discover(name) {
+		discovery.WriteString(activeLock(l, req.href(l.Root, false)))
+	}
+	return append(props, property{name: davName("lockdiscovery"), value: discovery.String()})
+}
+
+// isLiveProp check if the property is the live property that cannot be changed by PROPPATCH
+func isLiveProp(name xml.Name) bool {
+	if name.Space != davNS {
+		return false
+	}
+	switch name.Local {
+	case "displayname", "getlastmodified", "resourcetype", "getcontentlength", "getcontenttype", "getetag",
+		"supportedlock", "lockdiscovery", "creationdate", "getcontentlanguage":
+		return true
+	}
+	return false
+}
+
+// deadProps get the dead properties of the resource sorted by the name
+func (h *Handler) deadProps(name string) []property {
+	h.propsLock.RLock()
+	defer h.propsLock.RUnlock()
+	var props []property
+	for k, v := range h.props[name] {
+		props = append(props, property{name: k, value: v})
+	}
+	sort.Slice(props, func(i, j int) bool {
+		if props[i].name.Space != props[j].name.Space {
+			return props[i].name.Space < props[j].name.Space
+		}
+		return props[i].name.Local < props[j].name.Local
+	})
+	return props
+}
+
+// propstats get the property status of the resource for the PROPFIND request
+func (h *Handler) propstats(req *request, pf *propfindRequest, name string, info os.FileInfo) []propstat {
+	all := append(h.liveProps(req, name, info), h.deadProps(name)...)
+	if pf.PropName != nil {
+		names := make([]property, 0, len(all))
+		for _, p := range all {
+			names = append(names, property{name: p.name})
+		}
+		return []propstat{{status: http.StatusOK, props: names}}
+	}
+	if pf.Prop == nil {
+		return []propstat{{status: http.StatusOK, props: all}}
+	}
+	found := propstat{status: http.StatusOK}
+	missing := propstat{status: http.StatusNotFound}
+	for _, requested := range pf.Prop.Props {
+		ok := false
+		for _, p := range all {
+			if p.name == requested.XMLName {
+				found.props = append(found.props, p)
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			missing.props = append(missing.props, property{name: requested.XMLName})
+		}
+	}
+	var result []propstat
+	if len(found.props) > 0 {
+		result = append(result, found)
+	}
+	if len(missing.props) > 0 {
+		result = append(result, missing)
+	}
+	return result
+}
+
+// readBody read the XML body of the request into v. It returns false if the body is empty
+func readBody(r *http.Request, v interface{}) (bool, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return false, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return false, nil
+	}
+	return true, xml.Unmarshal(data, v)
+}
+
+func (h *Handler) propfind(req *request) {
+	d := depth(req.r.Header.Get("Depth"), infiniteDepth)
+	if d == -2 {
+		req.status(http.StatusBadRequest)
+		return
+	}
+	info, err := h.fs.Stat(req.name)
+	if err != nil {
+		req.status(statusOf(err))
+		return
+	}
+	pf := &propfindRequest{}
+	hasBody, err := readBody(req.r, pf)
+	if err != nil {
+		req.status(http.StatusBadRequest)
+		return
+	}
+	if !hasBody {
+		pf.AllProp = &struct{}{}
+	}
+	ms := newMultistatus()
+	var walk func(name string, info os.FileInfo, d int) error
+	walk = func(name string, info os.FileInfo, d int) error {
+		ms.response(req.href(name, info.IsDir()), h.propstats(req, pf, name, info))
+		if !info.IsDir() || d == 0 {
+			return nil
+		}
+		children, err := h.readDir(name)
+		if err != nil {
+			return err
+		}
+		next := infiniteDepth
+		if d == 1 {
+			next = 0
+		}
+		for _, child := range children {
+			if err = walk(path.Join(name, child.Name()), child, next); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err = walk(req.name, info, d); err != nil {
+		req.status(statusOf(err))
+		return
+	}
+	ms.write(req.w)
+}
+
+func (h *Handler) proppatch(req *request) {
+	if !req.confirm(h, req.name, false) {
+		return
+	}
+	info, err := h.fs.Stat(req.name)
+	if err != nil {
+		req.status(statusOf(err))
+		return
+	}
+	pu := &propertyUpdate{}
+	if hasBody, err := readBody(req.r, pu); err != nil || !hasBody {
+		req.status(http.StatusBadRequest)
+		return
+	}
+	// the update is atomic: if any property is protected, none of the properties is changed
+	var names, protected []property
+	for _, action := range pu.Actions {
+		for _, p := range action.Prop.Props {
+			if isLiveProp(p.XMLName) {
+				protected = append(protected, property{name: p.XMLName})
+			} else {
+				names = append(names, property{name: p.XMLName})
+			}
+		}
+	}
+	ms := newMultistatus()
+	href := req.href(req.name, info.IsDir())
+	if len(protected) > 0 {
+		stats := []propstat{{status: http.StatusForbidden, props: protected}}
+		if len(names) > 0 {
+			stats = append(stats, propstat{status: http.StatusFailedDependency, props: names})
+		}
+		ms.response(href, stats)
+		ms.write(req.w)
+		return
+	}
+	h.propsLock.Lock()
+	props := h.props[req.name]
+	if props == nil {
+		props = make(map[xml.Name]string)
+		h.props[req.name] = props
+	}
+	for _, action := range pu.Actions {
+		for _, p := range action.Prop.Props {
+			if action.XMLName.Local == "remove" {
+				delete(props, p.XMLName)
+			} else {
+				props[p.XMLName] = p.Inner
+			}
+		}
+	}
+	if len(props) == 0 {
+		delete(h.props, req.name)
+	}
+	h.propsLock.Unlock()
+	ms.response(href, []propstat{{status: http.StatusOK, props: names}})
+	ms.write(req.w)
+}
+
+// writeLock write the lock discovery of the created or refreshed lock
+func (req *request) writeLock(l *Lock, status int) {
+	body := `<?xml version="1.0" encoding="utf-8"?>` + "\n" + `<D:prop xmlns:D="DAV:"><D:lockdiscovery>` +
+		activeLock(l, req.href(l.Root, false)) + "</D:lockdiscovery></D:prop>"
+	req.w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	req.w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	req.w.WriteHeader(status)
+	io.WriteString(req.w, body)
+}
+
+func (h *Handler) lock(req *request) {
+	timeout := lockTimeout(req.r.Header.Get("Timeout"))
+	info := &lockInfo{}
+	hasBody, err := readBody(req.r, info)
+	if err != nil {
+		req.status(http.StatusBadRequest)
+		return
+	}
+	if !hasBody {
+		// the lock without body refreshes the lock of the token in the 'If' header
+		tokens := req.tokens()
+		if len(tokens) != 1 {
+			req.status(http.StatusBadRequest)
+			return
+		}
+		l := h.locks.Refresh(tokens[0], timeout)
+		if l == nil || !l.covers(req.name) {
+			req.status(http.StatusPreconditionFailed)
+			return
+		}
+		req.writeLock(l, http.StatusOK)
+		return
+	}
+	d := depth(req.r.Header.Get("Depth"), infiniteDepth)
+	if info.Write == nil || (info.Exclusive == nil && info.Shared == nil) || (d != 0 && d != infiniteDepth) {
+		req.status(http.StatusBadRequest)
+		return
+	}
+	// the conflicts with the existing locks are checked when the lock is created
+	l := Lock{Root: req.name, Infinite: d == infiniteDepth, Exclusive: info.Exclusive != nil, Timeout: timeout}
+	if info.Owner != nil {
+		l.Owner = strings.TrimSpace(info.Owner.Inner)
+	}
+	created := false
+	if _, err = h.fs.Stat(req.name); err != nil {
+		// the lock of the unmapped url creates the empty resource
+		if !h.parentExists(req.name) {
+			req.status(http.StatusConflict)
+			return
+		}
+		f, err := h.fs.OpenFile(req.name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if err != nil {
+			req.status(statusOf(err))
+			return
+		}
+		f.Close()
+		created = true
+	}
+	result := h.locks.Create(l)
+	if result == nil {
+		if created {
+			h.fs.RemoveAll(req.name)
+		}
+		req.status(http.StatusLocked)
+		return
+	}
+	req.w.Header().Set("Lock-Token", "<"+result.Token+">")
+	if created {
+		req.writeLock(result, http.StatusCreated)
+		return
+	}
+	req.writeLock(result, http.StatusOK)
+}
+
+func (h *Handler) unlock(req *request) {
+	token := strings.TrimSpace(req.r.Header.Get("Lock-Token"))
+	if len(token) < 3 || token[0] != '<' || token[len(token)-1] != '>' {
+		req.status(http.StatusBadRequest)
+		return
+	}
+	if !h.locks.Unlock(req.name, token[1:len(token)-1]) {
+		req.status(http.StatusConflict)
+		return
+	}
+	req.w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,202 @@
+package webdav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// davNS the namespace of the WebDAV elements
+const davNS = "DAV:"
+
+// anyElement the XML element with the raw content
+type anyElement struct {
+	XMLName xml.Name
+	Inner   string `xml:",innerxml"`
+}
+
+// propElement the 'prop' element that contains the properties
+type propElement struct {
+	Props []anyElement `xml:",any"`
+}
+
+// propfindRequest the body of the PROPFIND request
+type propfindRequest struct {
+	XMLName  xml.Name     `xml:"DAV: propfind"`
+	AllProp  *struct{}    `xml:"DAV: allprop"`
+	PropName *struct{}    `xml:"DAV: propname"`
+	Prop     *propElement `xml:"DAV: prop"`
+}
+
+// propAction the 'set' or 'remove' element of the PROPPATCH request
+type propAction struct {
+	XMLName xml.Name
+	Prop    propElement `xml:"DAV: prop"`
+}
+
+// propertyUpdate the body of the PROPPATCH request
+type propertyUpdate struct {
+	XMLName xml.Name     `xml:"DAV: propertyupdate"`
+	Actions []propAction `xml:",any"`
+}
+
+// lockInfo the body of the LOCK request
+type lockInfo struct {
+	XMLName   xml.Name  `xml:"DAV: lockinfo"`
+	Exclusive *struct{} `xml:"DAV: lockscope>exclusive"`
+	Shared    *struct{} `xml:"DAV: lockscope>shared"`
+	Write     *struct{} `xml:"DAV: locktype>write"`
+	Owner     *struct {
+		Inner string `xml:",innerxml"`
+	} `xml:"DAV: owner"`
+}
+
+// property the property name and the raw XML value
+type property struct {
+	name  xml.Name
+	value string
+}
+
+// propstat the properties with the same status
+type propstat struct {
+	status int
+	props  []property
+}
+
+func escape(s string) string {
+	buf := &bytes.Buffer{}
+	xml.EscapeText(buf, []byte(s))
+	return buf.String()
+}
+
+// writeProp write the property element with the namespace
+func writeProp(buf *bytes.Buffer, p property) {
+	var open, end string
+	switch p.name.Space {
+	case davNS:
+		open = "D:" + p.name.Local
+		end = open
+	case "":
+		open = p.name.Local + ` xmlns=""`
+		end = p.name.Local
+	default:
+		open = "x:" + p.name.Local + ` xmlns:x="` + escape(p.name.Space) + `"`
+		end = "x:" + p.name.Local
+	}
+	if len(p.value) == 0 {
+		fmt.Fprintf(buf, "<%s/>", open)
+		return
+	}
+	fmt.Fprintf(buf, "<%s>%s</%s>", open, p.value, end)
+}
+
+func statusLine(status int) string {
+	return "HTTP/1.1 " + strconv.Itoa(status) + " " + http.StatusText(status)
+}
+
+// multistatus the writer of the 207 Multi-Status response
+type multistatus struct {
+	buf bytes.Buffer
+}
+
+func newMultistatus() *multistatus {
+	ms := &multistatus{}
+	ms.buf.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n" + `<D:multistatus xmlns:D="DAV:">`)
+	return ms
+}
+
+// response add the response of the resource with the property status
+func (ms *multistatus) response(href string, propstats []propstat) {
+	fmt.Fprintf(&ms.buf, "<D:response><D:href>%s</D:href>", escape(href))
+	for _, ps := range propstats {
+		ms.buf.WriteString("<D:propstat><D:prop>")
+		for _, p := range ps.props {
+			writeProp(&ms.buf, p)
+		}
+		fmt.Fprintf(&ms.buf, "</D:prop><D:status>%s</D:status></D:propstat>", statusLine(ps.status))
+	}
+	ms.buf.WriteString("</D:response>")
+}
+
+// write write the multistatus response
+func (ms *multistatus) write(w http.ResponseWriter) {
+	ms.buf.WriteString("</D:multistatus>")
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(ms.buf.Len()))
+	w.WriteHeader(http.StatusMultiStatus)
+	w.Write(ms.buf.Bytes())
+}
+
+// timeoutValue format the lock timeout
+func timeoutValue(timeout time.Duration) string {
+	if timeout <= 0 {
+		return "Infinite"
+	}
+	return "Second-" + strconv.FormatInt(int64(timeout/time.Second), 10)
+}
+
+// activeLock get the XML of the active lock
+func activeLock(l *Lock, rootHref string) string {
+	buf := &bytes.Buffer{}
+	buf.WriteString("<D:activelock><D:locktype><D:write/></D:locktype><D:lockscope>")
+	if l.Exclusive {
+		buf.WriteString("<D:exclusive/>")
+	} else {
+		buf.WriteString("<D:shared/>")
+	}
+	buf.WriteString("</D:lockscope><D:depth>")
+	if l.Infinite {
+		buf.WriteString("infinity")
+	} else {
+		buf.WriteString("0")
+	}
+	buf.WriteString("</D:depth>")
+	if len(l.Owner) > 0 {
+		fmt.Fprintf(buf, "<D:owner>%s</D:owner>", l.Owner)
+	}
+	fmt.Fprintf(buf, "<D:timeout>%s</D:timeout>", timeoutValue(l.Timeout))
+	fmt.Fprintf(buf, "<D:locktoken><D:href>%s</D:href></D:locktoken>", escape(l.Token))
+	fmt.Fprintf(buf, "<D:lockroot><D:href>%s</D:href></D:lockroot></D:activelock>", escape(rootHref))
+	return buf.String()
+}
+
+// supportedLock the value of the 'supportedlock' property
+const supportedLock = "<D:lockentry><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockentry>" +
+	"<D:lockentry><D:lockscope><D:shared/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockentry>"
+
+// ifTokens get the state tokens of the 'If' header, for example '(<urn:uuid:...>)'. The resource tags,
+// the entity tags and the 'Not' conditions are ignored
+func ifTokens(header string) []string {
+	var tokens []string
+	depth := 0
+	for i := 0; i < len(header); i++ {
+		switch header[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case '<':
+			end := strings.IndexByte(header[i:], '>')
+			if end < 0 {
+				return tokens
+			}
+			if depth > 0 {
+				tokens = append(tokens, header[i+1:i+end])
+			}
+			i += end
+		case '[':
+			end := strings.IndexByte(header[i:], ']')
+			if end < 0 {
+				return tokens
+			}
+			i += end
+		}
+	}
+	return tokens
+}